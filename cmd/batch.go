@@ -0,0 +1,599 @@
+package cmd
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	imagedraw "image/draw"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/conf"
+	"github.com/ai-is-coming/dino/internal/providers"
+	"github.com/ai-is-coming/dino/internal/utils"
+
+	termcolor "github.com/fatih/color"
+	"github.com/shopspring/decimal"
+)
+
+// batch holds what every image of a batch run shares. Workers only write to the usage tally
+// and the disabled routes, both guarded by locks.
+type batch struct {
+	cfg          *conf.Config
+	routes       []providers.Route
+	prompt       string
+	systemPrompt string
+	format       json.RawMessage
+	temp         float64
+	topP         float64
+	inputRoot    string
+	jsonDir      string
+	metaDir      string
+	outputMeta   bool // see imageResult
+	bboxDir      string
+	pricing      priceTable
+	tally        *usageTally
+	// stems maps each image to the relative name of its results; see nameResults.
+	stems map[string]string
+
+	// Routes that fail for every image (bad key, unknown model) are disabled for the rest of
+	// the run.
+	mu       sync.Mutex
+	disabled []error
+}
+
+// detectResult is the outcome of sending one image through the routes.
+type detectResult struct {
+	dets        []detection
+	route       providers.Route
+	cached      bool
+	usage       providers.Usage
+	cost        float64
+	err         error
+	parseFailed bool
+}
+
+// run processes imgs on up to workers goroutines. Images that failed transiently get one more
+// pass after all others. The returned error ends the run early.
+func (b *batch) run(ctx context.Context, imgs []string, workers int) error {
+	deferred, err := b.pass(ctx, imgs, workers, true)
+	if err != nil || len(deferred) == 0 {
+		return err
+	}
+
+	_, err = b.pass(ctx, deferred, workers, false)
+	return err
+}
+
+// pass feeds imgs to the workers and returns the images deferred to a later pass.
+func (b *batch) pass(ctx context.Context, imgs []string, workers int, canDefer bool) ([]string, error) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		deferred []string
+		jobs     = make(chan string)
+	)
+
+	for range min(workers, len(imgs)) {
+		wg.Go(func() {
+			for imgPath := range jobs {
+				if ctx.Err() != nil {
+					continue
+				}
+
+				if b.processImage(ctx, imgPath, canDefer, workers > 1) {
+					mu.Lock()
+					deferred = append(deferred, imgPath)
+					mu.Unlock()
+				}
+
+				if err := b.noUsableRoute(); err != nil {
+					cancel(err)
+				}
+			}
+		})
+	}
+
+feed:
+	for _, imgPath := range imgs {
+		select {
+		case jobs <- imgPath:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
+	return deferred, nil
+}
+
+// processImage detects, draws and saves one image. It reports whether the image failed
+// transiently and should be tried again on a later pass.
+func (b *batch) processImage(ctx context.Context, imgPath string, canDefer, buffered bool) bool {
+	lg := newImageLog(buffered)
+	defer lg.flush()
+
+	absImgPath, err := filepath.Abs(imgPath)
+	if err != nil {
+		absImgPath = imgPath
+	}
+
+	termcolor.New(termcolor.FgCyan).Fprintf(lg.out, "processing: %s\n", absImgPath)
+	// Load image bytes for chat images
+	imgBytes, err := os.ReadFile(absImgPath)
+	if err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(lg.err, "skip %s: read image: %v\n", absImgPath, err)
+		return false
+	}
+
+	base := filepath.Base(imgPath)
+
+	// Decode before asking the model, so unreadable files cost nothing.
+	src, err := decodeImage(imgBytes)
+	if err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(lg.err, "skip %s: decode image: %v\n", base, err)
+		return false
+	}
+
+	res := b.detect(ctx, lg, imgPath, src.data)
+	if res.err != nil && canDefer && retryLater(res.err) {
+		termcolor.New(termcolor.FgYellow).Fprintf(
+			lg.err, "defer %s: %v; retrying after the remaining images\n", base, res.err,
+		)
+		return true
+	}
+
+	if res.err != nil {
+		if res.parseFailed {
+			// Ensure downstream can read a valid JSON file even if model output is invalid
+			b.writeResult(lg, imgPath, []exportDet{}, imageMeta{
+				Usage:   usageOrNil(res.usage),
+				CostUSD: res.cost,
+				Error:   res.err.Error(),
+			})
+		}
+		termcolor.New(termcolor.FgRed).Fprintf(lg.err, "skip %s: all providers failed: %v\n", base, res.err)
+
+		return false
+	}
+
+	b.annotate(lg, imgPath, src, res)
+	return false
+}
+
+// detect tries each usable route in priority order until one yields parseable detections.
+// Each call collects the response in its own buffer.
+func (b *batch) detect(ctx context.Context, lg *imageLog, imgPath string, imgBytes []byte) detectResult {
+	var (
+		res     detectResult
+		tried   int
+		printed bool // the previous route's answer is on screen
+	)
+
+	base := filepath.Base(imgPath)
+
+	for i, rt := range b.routes {
+		if b.routeDisabled(i) {
+			continue
+		}
+
+		if tried > 0 {
+			if printed {
+				printDiscarded(lg.out)
+			}
+			termcolor.New(termcolor.FgYellow).Fprintf(lg.err, "falling back to %s for %s\n", rt, base)
+		}
+		tried++
+
+		var sb strings.Builder
+
+		opts := b.chatOptions(rt, imgBytes, filepath.ToSlash(b.relPath(imgPath)), lg, &sb)
+
+		logPrompts(lg.out, b.systemPrompt, b.prompt)
+		usage, err := rt.Provider.Chat(ctx, opts)
+		err = providers.Classify(rt.Name, err)
+		// Streamed content is echoed as it arrives; a buffered log only shows the full response.
+		printed = sb.Len() > 0 && (!lg.buffered || err == nil)
+		b.tally.add(rt, usage)
+		res.usage.Add(usage)
+		res.cost += b.pricing.cost(rt.Model, usage)
+		if err != nil {
+			termcolor.New(termcolor.FgRed).Fprintf(lg.err, "error generating for %s via %s: %v\n", imgPath, rt, err)
+			// An earlier route's unparseable answer still gets its empty result written.
+			res.err = err
+
+			if routeUnusable(err) && b.disable(i, err) {
+				termcolor.New(termcolor.FgRed).Fprintf(lg.err, "disabling %s for the rest of the run\n", rt)
+			}
+
+			continue
+		}
+
+		if usage.Cached {
+			termcolor.New(termcolor.FgHiBlack).Fprintf(lg.out, "\n(cached response)")
+		}
+		out := repairLLMOutput(lg.err, imgPath, sb.String())
+		termcolor.New(termcolor.FgHiGreen).Fprintf(lg.out, "\nassistant response: %s\n", out)
+
+		parsed, err := parseDetections(out)
+		if err != nil {
+			termcolor.New(termcolor.FgYellow).Fprintf(lg.err, "warn %s: parse json from %s: %v\n", base, rt, err)
+			res.err, res.parseFailed = err, true
+
+			continue
+		}
+		res.dets, res.route, res.cached, res.err = parsed, rt, usage.Cached, nil
+
+		break
+	}
+
+	// Another worker may have disabled the last route before this image got a turn.
+	if tried == 0 {
+		res.err = b.noUsableRoute()
+	}
+	return res
+}
+
+// chatOptions builds the request for one image on rt; name is its slash-separated path
+// relative to the input. Content is collected into sb; it is also echoed while streaming
+// unless the log is buffered.
+func (b *batch) chatOptions(
+	rt providers.Route, imgBytes []byte, name string, lg *imageLog, sb *strings.Builder,
+) providers.ChatOptions {
+	return providers.NewChatOptions(
+		rt.Model, b.prompt,
+		providers.WithStream(stream),
+		providers.WithTemperature(b.temp),
+		providers.WithTopP(b.topP),
+		providers.WithThink(b.cfg.Think),
+		providers.WithReasoningEffort(b.cfg.ReasoningEffort),
+		providers.WithThinkingBudget(b.cfg.ThinkingBudget),
+		outputControls(b.cfg),
+		providers.WithImages(imgBytes),
+		providers.WithImageNames(name),
+		providers.WithFormat(b.format),
+		providers.WithNoResponseFormat(b.cfg.NoResponseFormat),
+		providers.WithSystemPrompt(b.systemPrompt),
+		providers.WithCacheable(func(content string) bool {
+			_, err := parseDetections(repairLLMOutput(io.Discard, name, content))
+			return err == nil
+		}),
+		providers.WithOnDelta(func(content, thinking string) error {
+			if stream && thinking != "" {
+				if !lg.buffered {
+					termcolor.New(termcolor.FgHiWhite).Fprintf(lg.out, "%s", thinking)
+				}
+			} else if content != "" {
+				if !lg.buffered {
+					termcolor.New(termcolor.FgHiWhite).Fprintf(lg.out, "%s", content)
+				}
+				sb.WriteString(content)
+			}
+			return nil
+		}),
+		providers.WithOnRetry(func(attempt int, err error, wait time.Duration) {
+			// Drop partial output from the failed attempt before the retry streams again.
+			sb.Reset()
+			retryLogger(lg.err)(attempt, err, wait)
+		}),
+	)
+}
+
+// annotate scales the detections to pixel space, draws them and saves the JSON result and
+// the annotated image.
+func (b *batch) annotate(lg *imageLog, imgPath string, src sourceImage, res detectResult) {
+	base := filepath.Base(imgPath)
+
+	// Prepare image for drawing
+	bounds := src.img.Bounds()
+	dst := image.NewRGBA(bounds)
+	imagedraw.Draw(dst, bounds, src.img, bounds.Min, imagedraw.Src)
+
+	// Build export detections with integer, pixel-space bbox values
+	exportDets := make([]exportDet, 0, len(res.dets))
+
+	for _, d := range res.dets {
+		label := d.Label
+		x1, y1, x2, y2 := b.pixelBox(d, bounds)
+
+		// Append to export list with scaled bbox
+		exportDets = append(exportDets, exportDet{Label: label, BBox: []int{x1, y1, x2, y2}})
+
+		col := colorForLabel(label, b.cfg.Classes, b.cfg.Colors)
+		utils.DrawRect(dst, x1, y1, x2, y2, col, rectThickness)
+		// draw label text on a colored background near the top-left corner of the box
+		bg := color.RGBA{R: col.R, G: col.G, B: col.B, A: bgAlpha}
+		utils.DrawLabel(dst, x1, y1, label, color.RGBA{255, 255, 255, 255}, bg)
+	}
+
+	meta := imageMeta{
+		Provider:    res.route.Name,
+		Model:       res.route.Model,
+		Usage:       usageOrNil(res.usage),
+		CostUSD:     res.cost,
+		Cached:      res.cached,
+		Fingerprint: b.resultFingerprint(res.route.Name, res.route.Model),
+	}
+
+	// Save annotated image to outputs/bbox under the same relative path. It goes first so
+	// that a sidecar without an error always has its image next to it.
+	outImgPath, err := saveAnnotated(dst, src.format, filepath.Join(b.bboxDir, b.relPath(imgPath)))
+	if err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(lg.err, "warn %s: save annotated image: %v\n", base, err)
+		meta.Error = "save annotated image: " + err.Error()
+	}
+
+	// Save scaled JSON detections to outputs/json, and the route that produced them to outputs/meta
+	b.writeResult(lg, imgPath, exportDets, meta)
+
+	if err == nil {
+		termcolor.New(termcolor.FgGreen).Fprintf(lg.out, "saved %s\n\n", outImgPath)
+	}
+}
+
+// saveAnnotated encodes img to suit the source format and writes it atomically. It returns
+// the path actually written.
+func saveAnnotated(img image.Image, format, path string) (string, error) {
+	path, data, err := encodeAnnotated(img, format, path)
+	if err != nil {
+		return path, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), permDir); err != nil {
+		return path, err
+	}
+	return path, utils.WriteFileAtomic(path, data, permFile)
+}
+
+// relPath is imgPath relative to the input, which the output folders mirror.
+func (b *batch) relPath(imgPath string) string {
+	rel, err := filepath.Rel(b.inputRoot, imgPath)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return filepath.Base(imgPath)
+	}
+	return rel
+}
+
+// nameResults picks the result name of every image in the run: its relative path without
+// the extension, so a.jpg is saved as a.json. Images whose names would collide, such as a.jpg
+// and a.png in one folder, keep their extension instead (a.jpg.json and a.png.json).
+func (b *batch) nameResults(imgs []string) {
+	byStem := make(map[string][]string, len(imgs))
+	for _, imgPath := range imgs {
+		// Compared case-insensitively, as A.jpg and a.png collide on macOS and Windows.
+		key := strings.ToLower(trimExt(b.relPath(imgPath)))
+		byStem[key] = append(byStem[key], imgPath)
+	}
+
+	b.stems = make(map[string]string, len(imgs))
+	for _, paths := range byStem {
+		for _, imgPath := range paths {
+			rel := b.relPath(imgPath)
+			if len(paths) == 1 {
+				rel = trimExt(rel)
+			}
+			b.stems[imgPath] = rel
+		}
+	}
+}
+
+// resultStem returns the relative result name of imgPath, without ".json".
+func (b *batch) resultStem(imgPath string) string {
+	if stem, ok := b.stems[imgPath]; ok {
+		return stem
+	}
+	return trimExt(b.relPath(imgPath))
+}
+
+func trimExt(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path))
+}
+
+// jsonPath is where the detections for imgPath are saved.
+func (b *batch) jsonPath(imgPath string) string {
+	return filepath.Join(b.jsonDir, b.resultStem(imgPath)+".json")
+}
+
+// metaPath is where the sidecar for imgPath is saved.
+func (b *batch) metaPath(imgPath string) string {
+	return filepath.Join(b.metaDir, b.resultStem(imgPath)+".json")
+}
+
+// writeResult saves the detections, then the sidecar. The sidecar goes last, so one without
+// an error means the whole result is on disk.
+func (b *batch) writeResult(lg *imageLog, imgPath string, dets []exportDet, meta imageMeta) {
+	var result any = dets
+	if b.outputMeta {
+		result = imageResult{imageMeta: meta, Detections: dets}
+	}
+
+	writeJSONFile(lg.err, b.jsonPath(imgPath), result)
+	writeJSONFile(lg.err, b.metaPath(imgPath), meta)
+}
+
+// resultFingerprint identifies the settings that shaped a result: the route that produced
+// it, both prompts, the response schema, the bbox scale and the outputs/json layout. --resume
+// only trusts results whose fingerprint still matches, so adding or reordering fallbacks keeps
+// finished results.
+func (b *batch) resultFingerprint(provider, model string) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%s\x00%s\x00%d\x00%t",
+		provider, model, b.prompt, b.systemPrompt, b.format, b.cfg.BboxScale, b.outputMeta)
+
+	return hex.EncodeToString(h.Sum(nil)[:16])
+}
+
+// pending drops the images that already have a complete result with a matching fingerprint.
+func (b *batch) pending(imgs []string) []string {
+	out := make([]string, 0, len(imgs))
+	for _, imgPath := range imgs {
+		if !b.completed(imgPath) {
+			out = append(out, imgPath)
+		}
+	}
+	return out
+}
+
+// completed reports whether imgPath has a saved result without error for this configuration.
+func (b *batch) completed(imgPath string) bool {
+	data, err := os.ReadFile(b.metaPath(imgPath))
+	if err != nil {
+		return false
+	}
+
+	var meta imageMeta
+	if err := json.Unmarshal(data, &meta); err != nil || meta.Error != "" || !b.hasRoute(meta.Provider, meta.Model) ||
+		meta.Fingerprint != b.resultFingerprint(meta.Provider, meta.Model) {
+		return false
+	}
+
+	_, err = os.Stat(b.jsonPath(imgPath))
+	return err == nil
+}
+
+// hasRoute reports whether the provider and model are still one of the configured routes, so
+// results from a model that was swapped out are redone.
+func (b *batch) hasRoute(provider, model string) bool {
+	return slices.ContainsFunc(b.routes, func(rt providers.Route) bool {
+		return rt.Name == provider && rt.Model == model
+	})
+}
+
+// pixelBox converts a detection to an ordered pixel box clamped to bounds.
+func (b *batch) pixelBox(d detection, bounds image.Rectangle) (int, int, int, int) {
+	x1, y1, x2, y2 := 0, 0, 0, 0
+	if len(d.BBox) >= bboxMinLen {
+		if b.cfg.BboxScale > 0 {
+			// Expect normalized bbox [x1, y1, x2, y2] in 0..bboxScale (floats or ints)
+			x1, y1, x2, y2 = utils.DenormalizeBbox(
+				strconv.FormatFloat(d.BBox[0], 'f', -1, 64),
+				strconv.FormatFloat(d.BBox[1], 'f', -1, 64),
+				strconv.FormatFloat(d.BBox[2], 'f', -1, 64),
+				strconv.FormatFloat(d.BBox[3], 'f', -1, 64),
+				bounds.Dx(), bounds.Dy(),
+				b.cfg.BboxScale,
+			)
+		} else {
+			// Expect absolute pixel bbox as floats/ints [x1, y1, x2, y2]
+			x1 = int(decimal.NewFromFloat(d.BBox[0]).IntPart())
+			y1 = int(decimal.NewFromFloat(d.BBox[1]).IntPart())
+			x2 = int(decimal.NewFromFloat(d.BBox[2]).IntPart())
+			y2 = int(decimal.NewFromFloat(d.BBox[3]).IntPart())
+		}
+	}
+
+	// normalize and clamp
+	if x1 > x2 {
+		x1, x2 = x2, x1
+	}
+	if y1 > y2 {
+		y1, y2 = y2, y1
+	}
+	x1 = utils.Clamp(x1, bounds.Min.X, bounds.Max.X-1)
+	x2 = utils.Clamp(x2, bounds.Min.X, bounds.Max.X-1)
+	y1 = utils.Clamp(y1, bounds.Min.Y, bounds.Max.Y-1)
+	y2 = utils.Clamp(y2, bounds.Min.Y, bounds.Max.Y-1)
+	return x1, y1, x2, y2
+}
+
+func (b *batch) routeDisabled(i int) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.disabled[i] != nil
+}
+
+// disable marks route i unusable and reports whether it was usable until now.
+func (b *batch) disable(i int, err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.disabled[i] != nil {
+		return false
+	}
+	b.disabled[i] = err
+	return true
+}
+
+// noUsableRoute returns an error once every route has been disabled.
+func (b *batch) noUsableRoute() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if slices.Contains(b.disabled, nil) {
+		return nil
+	}
+	return fmt.Errorf("no usable provider left: %w", errors.Join(b.disabled...))
+}
+
+// imageLog is where one image's output goes. Serial runs write straight through so streamed
+// tokens show up live; concurrent runs buffer everything and flush it in one piece, so lines
+// of different images never interleave.
+type imageLog struct {
+	out      io.Writer
+	err      io.Writer
+	buffered bool
+	parts    []logPart
+}
+
+// logPart is one buffered write and the stream it belongs to.
+type logPart struct {
+	w io.Writer
+	b []byte
+}
+
+// logMu serializes flushes of buffered image logs.
+var logMu sync.Mutex
+
+func newImageLog(buffered bool) *imageLog {
+	lg := &imageLog{out: os.Stdout, err: os.Stderr, buffered: buffered}
+	if buffered {
+		lg.out = logWriter{lg: lg, w: os.Stdout}
+		lg.err = logWriter{lg: lg, w: os.Stderr}
+	}
+	return lg
+}
+
+// flush replays buffered writes in order, each to its own stream.
+func (lg *imageLog) flush() {
+	if len(lg.parts) == 0 {
+		return
+	}
+
+	logMu.Lock()
+	defer logMu.Unlock()
+
+	for _, p := range lg.parts {
+		_, _ = p.w.Write(p.b)
+	}
+	lg.parts = nil
+}
+
+// logWriter appends writes destined for w to an imageLog.
+type logWriter struct {
+	lg *imageLog
+	w  io.Writer
+}
+
+func (w logWriter) Write(p []byte) (int, error) {
+	w.lg.parts = append(w.lg.parts, logPart{w: w.w, b: append([]byte(nil), p...)})
+	return len(p), nil
+}
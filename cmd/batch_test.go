@@ -0,0 +1,432 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/conf"
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+// scriptedProvider answers every call with the same content, then err, and counts the calls.
+type scriptedProvider struct {
+	content string
+	err     error
+
+	mu    sync.Mutex
+	calls int
+}
+
+func (p *scriptedProvider) Chat(_ context.Context, opts providers.ChatOptions) (providers.Usage, error) {
+	p.mu.Lock()
+	p.calls++
+	p.mu.Unlock()
+
+	if opts.OnDelta != nil && p.content != "" {
+		if err := opts.OnDelta(p.content, ""); err != nil {
+			return providers.Usage{}, err
+		}
+	}
+	return providers.Usage{}, p.err
+}
+
+func (p *scriptedProvider) count() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.calls
+}
+
+// failingMock returns a mock provider that fails every call with status, or with a dropped
+// connection when status is 0.
+func failingMock(t *testing.T, status int) providers.Provider {
+	t.Helper()
+
+	p, err := providers.NewMock(providers.ProviderConfig{
+		Mock: providers.MockConfig{ErrorRate: 1, ErrorStatus: status},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func newTestBatch(t *testing.T, routes ...providers.Route) *batch {
+	t.Helper()
+
+	out := t.TempDir()
+	b := &batch{
+		cfg:       &conf.Config{},
+		routes:    routes,
+		prompt:    "detect",
+		format:    responseFormat(&conf.Config{}),
+		inputRoot: t.TempDir(),
+		jsonDir:   filepath.Join(out, "json"),
+		metaDir:   filepath.Join(out, "meta"),
+		bboxDir:   filepath.Join(out, "bbox"),
+		pricing:   priceTable{},
+		tally:     newUsageTally(),
+		disabled:  make([]error, len(routes)),
+	}
+
+	return b
+}
+
+// writeTestPNG writes a small PNG named name under b's input folder and returns its path.
+func writeTestPNG(t *testing.T, b *batch, name string) string {
+	t.Helper()
+
+	path := filepath.Join(b.inputRoot, name)
+	if err := os.MkdirAll(filepath.Dir(path), permDir); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	if err := png.Encode(f, image.NewRGBA(image.Rect(0, 0, 8, 8))); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDetect_FallsBackInOrderOnParseError(t *testing.T) {
+	bad := &scriptedProvider{content: "no boxes here"}
+	good := &scriptedProvider{content: `[{"label":"cat","bbox":[1,2,3,4]}]`}
+	unused := &scriptedProvider{content: "[]"}
+
+	b := newTestBatch(t,
+		providers.Route{Name: "a", Model: "m", Provider: bad},
+		providers.Route{Name: "b", Model: "m", Provider: good},
+		providers.Route{Name: "c", Model: "m", Provider: unused},
+	)
+
+	res := b.detect(context.Background(), newImageLog(true), "x.png", []byte("img"))
+	if res.err != nil {
+		t.Fatalf("err = %v", res.err)
+	}
+
+	if res.route.Name != "b" || len(res.dets) != 1 || res.dets[0].Label != "cat" {
+		t.Fatalf("route = %s, dets = %+v", res.route, res.dets)
+	}
+
+	if bad.count() != 1 || good.count() != 1 || unused.count() != 0 {
+		t.Fatalf("calls = %d, %d, %d; want 1, 1, 0", bad.count(), good.count(), unused.count())
+	}
+}
+
+func TestProcessImage_KeepsParseFailureWhenLaterRouteErrors(t *testing.T) {
+	b := newTestBatch(t,
+		providers.Route{Name: "a", Model: "m", Provider: &scriptedProvider{content: "not json"}},
+		providers.Route{Name: "mock", Model: "m", Provider: failingMock(t, 400)},
+	)
+	img := writeTestPNG(t, b, "x.png")
+
+	if b.processImage(context.Background(), img, false, true) {
+		t.Fatal("image was deferred")
+	}
+
+	data, err := os.ReadFile(b.jsonPath(img))
+	if err != nil {
+		t.Fatalf("parse failure result not written: %v", err)
+	}
+
+	if string(data) != "[]" {
+		t.Fatalf("json = %s, want []", data)
+	}
+
+	if b.completed(img) {
+		t.Fatal("a failed result counts as completed")
+	}
+}
+
+func TestProcessImage_OutputMetaWritesObjects(t *testing.T) {
+	for _, outputMeta := range []bool{false, true} {
+		b := newTestBatch(t, providers.Route{
+			Name: "a", Model: "m", Provider: &scriptedProvider{content: `[{"label":"cat","bbox":[1,2,3,4]}]`},
+		})
+		b.outputMeta = outputMeta
+		img := writeTestPNG(t, b, "x.png")
+
+		b.processImage(context.Background(), img, false, true)
+
+		data, err := os.ReadFile(b.jsonPath(img))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !outputMeta {
+			if string(data) != `[{"label":"cat","bbox":[1,2,3,4]}]` {
+				t.Fatalf("json = %s, want a bare array", data)
+			}
+			continue
+		}
+
+		var res imageResult
+		if err := json.Unmarshal(data, &res); err != nil {
+			t.Fatalf("json = %s: %v", data, err)
+		}
+
+		if res.Provider != "a" || res.Model != "m" || len(res.Detections) != 1 || res.Detections[0].Label != "cat" {
+			t.Fatalf("result = %+v", res)
+		}
+
+		if !b.completed(img) {
+			t.Fatal("an object result does not count as completed")
+		}
+	}
+}
+
+func TestDetect_MarksOutputOfFailedRouteAsDiscarded(t *testing.T) {
+	cut := fmt.Errorf("stream: %w", io.ErrUnexpectedEOF)
+	b := newTestBatch(t,
+		providers.Route{Name: "a", Model: "m", Provider: &scriptedProvider{content: `[{"label":"ca`, err: cut}},
+		providers.Route{Name: "b", Model: "m", Provider: &scriptedProvider{content: "[]"}},
+	)
+
+	var out strings.Builder
+
+	lg := &imageLog{out: &out, err: io.Discard}
+	if res := b.detect(context.Background(), lg, "x.png", []byte("img")); res.err != nil {
+		t.Fatalf("err = %v", res.err)
+	}
+
+	partial := strings.Index(out.String(), `[{"label":"ca`)
+	rule := strings.Index(out.String(), "discarded")
+	if partial < 0 || rule < partial {
+		t.Fatalf("out = %q, want the partial answer followed by a discard notice", out.String())
+	}
+}
+
+func TestDetect_DisablesUnusableRoutes(t *testing.T) {
+	good := &scriptedProvider{content: "[]"}
+	b := newTestBatch(t,
+		providers.Route{Name: "mock", Model: "m", Provider: failingMock(t, 401)},
+		providers.Route{Name: "b", Model: "m", Provider: good},
+	)
+
+	for range 3 {
+		if res := b.detect(context.Background(), newImageLog(true), "x.png", []byte("img")); res.err != nil {
+			t.Fatalf("err = %v", res.err)
+		}
+	}
+
+	if !b.routeDisabled(0) || b.routeDisabled(1) {
+		t.Fatalf("disabled = %v", b.disabled)
+	}
+
+	if good.count() != 3 {
+		t.Fatalf("fallback calls = %d, want 3", good.count())
+	}
+
+	if err := b.noUsableRoute(); err != nil {
+		t.Fatalf("noUsableRoute = %v with a usable route left", err)
+	}
+
+	b.disable(1, errors.New("gone"))
+	if err := b.noUsableRoute(); err == nil {
+		t.Fatal("expected an error once every route is disabled")
+	}
+}
+
+func TestRouteUnusable(t *testing.T) {
+	cases := []struct {
+		err  error
+		want bool
+	}{
+		{&providers.Error{Kind: providers.ErrAuth, Err: errors.New("bad key")}, true},
+		{&providers.Error{Kind: providers.ErrModelNotFound, Err: errors.New("no model")}, true},
+		{&providers.Error{Kind: providers.ErrTransient, Err: errors.New("503")}, false},
+		{&providers.Error{Kind: providers.ErrContentFiltered, Err: errors.New("blocked")}, false},
+		{errors.New("parse"), false},
+	}
+	for _, c := range cases {
+		if got := routeUnusable(c.err); got != c.want {
+			t.Errorf("routeUnusable(%v) = %t, want %t", c.err, got, c.want)
+		}
+	}
+}
+
+func TestNameResults_AddsExtensionOnlyOnCollision(t *testing.T) {
+	b := newTestBatch(t)
+	in := func(rel string) string { return filepath.Join(b.inputRoot, filepath.FromSlash(rel)) }
+
+	imgs := []string{in("a.jpg"), in("A.png"), in("b.jpg"), in("sub/a.jpg"), in("sub/c.webp")}
+	b.nameResults(imgs)
+
+	want := []string{"a.jpg.json", "A.png.json", "b.json", "sub/a.json", "sub/c.json"}
+	for i, imgPath := range imgs {
+		got, _ := filepath.Rel(b.jsonDir, b.jsonPath(imgPath))
+		if filepath.ToSlash(got) != want[i] {
+			t.Errorf("jsonPath(%s) = %s, want %s", imgs[i], got, want[i])
+		}
+	}
+}
+
+func TestPending_SkipsCompletedResultsWithMatchingFingerprint(t *testing.T) {
+	good := providers.Route{Name: "a", Model: "m", Provider: &scriptedProvider{content: "[]"}}
+	b := newTestBatch(t, good)
+	done, todo := writeTestPNG(t, b, "done.png"), writeTestPNG(t, b, "todo.png")
+
+	if b.processImage(context.Background(), done, false, true) {
+		t.Fatal("image was deferred")
+	}
+
+	// rerun builds a batch over the same folders, as a later --resume run would.
+	rerun := func(prompt string, routes ...providers.Route) *batch {
+		r := newTestBatch(t, routes...)
+		r.prompt = prompt
+		r.inputRoot, r.jsonDir, r.metaDir, r.bboxDir = b.inputRoot, b.jsonDir, b.metaDir, b.bboxDir
+
+		return r
+	}
+
+	extra := providers.Route{Name: "b", Model: "other", Provider: &scriptedProvider{content: "[]"}}
+	swapped := providers.Route{Name: "a", Model: "m2", Provider: good.Provider}
+	cases := []struct {
+		name string
+		b    *batch
+		want []string
+	}{
+		{"same settings", rerun(b.prompt, good), []string{todo}},
+		{"fallback added in front", rerun(b.prompt, extra, good), []string{todo}},
+		{"prompt changed", rerun("detect dogs", good), []string{done, todo}},
+		{"model changed", rerun(b.prompt, swapped), []string{done, todo}},
+		{"producing route removed", rerun(b.prompt, extra), []string{done, todo}},
+	}
+	for _, c := range cases {
+		if got := c.b.pending([]string{done, todo}); !slices.Equal(got, c.want) {
+			t.Errorf("%s: pending = %v, want %v", c.name, got, c.want)
+		}
+	}
+
+	if err := os.Remove(b.jsonPath(done)); err != nil {
+		t.Fatal(err)
+	}
+
+	if b.completed(done) {
+		t.Fatal("a result without its detections counts as completed")
+	}
+}
+
+// countingProvider counts the successful calls per image path.
+type countingProvider struct {
+	next providers.Provider
+
+	mu        sync.Mutex
+	calls     int
+	successes map[string]int
+}
+
+func (p *countingProvider) Chat(ctx context.Context, opts providers.ChatOptions) (providers.Usage, error) {
+	usage, err := p.next.Chat(ctx, opts)
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.calls++
+	if err == nil {
+		p.successes[opts.ImageNames[0]]++
+	}
+	return usage, err
+}
+
+func TestRun_WritesEveryImageOnceAcrossWorkersAndDeferredPass(t *testing.T) {
+	mock, err := providers.NewMock(providers.ProviderConfig{
+		Mock: providers.MockConfig{ErrorRate: 0.3, ErrorStatus: 503, Seed: 7},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	counter := &countingProvider{next: mock, successes: map[string]int{}}
+	b := newTestBatch(t, providers.Route{Name: "mock", Model: "m", Provider: counter})
+
+	const n = 40
+
+	imgs := make([]string, 0, n)
+	for i := range n {
+		imgs = append(imgs, writeTestPNG(t, b, fmt.Sprintf("dir%d/img%02d.png", i%3, i)))
+	}
+	b.nameResults(imgs)
+
+	if err := b.run(context.Background(), imgs, 4); err != nil {
+		t.Fatalf("run: %v", err)
+	}
+
+	// Failed images get one more call on the second pass and no more.
+	if counter.calls <= n || counter.calls > 2*n {
+		t.Fatalf("calls = %d, want a deferred pass over some of %d images", counter.calls, n)
+	}
+
+	written := 0
+	for _, imgPath := range imgs {
+		name := filepath.ToSlash(b.relPath(imgPath))
+
+		switch counter.successes[name] {
+		case 0:
+			if _, err := os.Stat(b.jsonPath(imgPath)); err == nil {
+				t.Errorf("%s failed twice but has a result", name)
+			}
+		case 1:
+			if !b.completed(imgPath) {
+				t.Errorf("%s succeeded but its result is incomplete", name)
+			}
+			written++
+		default:
+			t.Errorf("%s was detected %d times", name, counter.successes[name])
+		}
+	}
+
+	if written < n/2 {
+		t.Fatalf("only %d of %d images written", written, n)
+	}
+
+	// Atomic writes leave no temporary files behind.
+	var files int
+	_ = filepath.WalkDir(b.jsonDir, func(path string, d os.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files++
+		}
+		return err
+	})
+
+	if files != written {
+		t.Fatalf("%d files in outputs/json, want %d", files, written)
+	}
+}
+
+func TestImageLog_BuffersUntilFlush(t *testing.T) {
+	var out, errOut strings.Builder
+
+	lg := &imageLog{buffered: true}
+	lg.out = logWriter{lg: lg, w: &out}
+	lg.err = logWriter{lg: lg, w: &errOut}
+
+	fmt.Fprint(lg.out, "processing a.png\n")
+	fmt.Fprint(lg.err, "warn a.png\n")
+	fmt.Fprint(lg.out, "saved a.png\n")
+
+	if out.Len() != 0 || errOut.Len() != 0 {
+		t.Fatal("buffered log wrote before flush")
+	}
+
+	lg.flush()
+
+	if out.String() != "processing a.png\nsaved a.png\n" || errOut.String() != "warn a.png\n" {
+		t.Fatalf("out = %q, err = %q", out.String(), errOut.String())
+	}
+}
@@ -0,0 +1,84 @@
+package cmd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/conf"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+const defaultCachePruneAge = 30 * 24 * time.Hour
+
+var (
+	cachePruneOlderThan time.Duration
+	cachePruneAll       bool
+)
+
+// cacheCmd groups response cache maintenance commands.
+var cacheCmd = &cobra.Command{
+	Use:   "cache",
+	Short: "Manage the provider response cache",
+	Long: "Manage the on-disk cache of provider responses used by 'dino run'. " +
+		"Entries are keyed by provider, model, prompts, schema, sampling settings and image hash.",
+}
+
+// cachePruneCmd deletes cache entries that were not used recently.
+var cachePruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Delete cached responses not used recently",
+	Long:  "Delete cached responses not used within --older-than (default 30 days), or all of them with --all.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := conf.Load()
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+
+		cache, err := openResponseCache(cfg)
+		if err != nil {
+			return err
+		}
+
+		age := cachePruneOlderThan
+		if cachePruneAll {
+			age = 0
+		} else if age <= 0 {
+			return fmt.Errorf("--older-than must be positive; use --all to clear the cache")
+		}
+
+		removed, freed, err := cache.Prune(age)
+		if err != nil {
+			return err
+		}
+		color.New(color.FgGreen).Printf(
+			"removed %d cached responses (%s) from %s\n", removed, formatBytes(freed), cache.Dir(),
+		)
+		return nil
+	},
+}
+
+func attachCacheFlags() {
+	cachePruneCmd.Flags().DurationVar(
+		&cachePruneOlderThan, "older-than", defaultCachePruneAge, "remove entries not used within this duration",
+	)
+	cachePruneCmd.Flags().BoolVar(&cachePruneAll, "all", false, "remove every cached response")
+	cacheCmd.AddCommand(cachePruneCmd)
+}
+
+// formatBytes renders n with a binary unit suffix.
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), strings.ToUpper("kmgtpe")[exp])
+}
@@ -54,15 +54,105 @@ var confCmd = &cobra.Command{
 
 const defaultConfYAML = `provider: ollama
 model: qwen3-vl:32b
-# apiKey: your-api-key-here  # Required for openai/gemini provider, optional for ollama
-# baseURL: https://api.openai.com  # Optional: custom API endpoint
-# authType: api_key  # api_key (X-Api-Key header) or auth_token (Bearer token)
+# apiKey: your-api-key-here  # Required for openai/azure/gemini/anthropic provider, optional bearer token for ollama
+# baseURL: https://api.openai.com  # Optional: custom API endpoint (ollama defaults to OLLAMA_HOST)
+# authType: api_key  # api_key (X-Api-Key header) or auth_token (Bearer token); ollama defaults to auth_token
 # noResponseFormat: false  # Set to true for APIs that don't support response_format
 temperature: 0.6
 topP: 0.95
 stream: true
+# concurrency: 8  # images processed in parallel; streamed output is then shown per image once done
+# Reasoning models: think enables thinking output (shown while streaming); reasoningEffort maps to
+# OpenAI reasoning_effort (temperature and topP are then not sent) and to a Gemini/Anthropic
+# thinking budget unless thinkingBudget is set
+# think: false
+# reasoningEffort: medium  # none, minimal, low, medium, high
+# thinkingBudget: 0
+# Output controls: maxTokens stops runaway generations (ollama num_predict, openai
+# max_completion_tokens, gemini maxOutputTokens); seed makes sampling reproducible where supported
+# maxTokens: 2048
+# seed: 42
+# stop: ["</answer>"]
+# imageDetail: auto  # openai only: auto, low or high
+# Ollama-only settings (ignored by other providers)
+# ollama:
+#   keepAlive: 10m  # how long the model stays loaded; "-1" keeps it loaded
+#   numCtx: 8192  # context window size
+#   numPredict: 2048  # max tokens to generate; maxTokens above takes precedence
+# OpenAI-only settings (ignored by other providers)
+# openai:
+#   api: responses  # "chat" (default, /v1/chat/completions) or "responses" (/v1/responses)
+# Gemini-only settings (ignored by other providers)
+# gemini:
+#   safetySettings:  # per-category block thresholds; blocked responses fail with the reason
+#     - category: HARM_CATEGORY_DANGEROUS_CONTENT
+#       threshold: BLOCK_ONLY_HIGH
+#   uploadThreshold: 10485760  # bytes; larger images are uploaded via the Files API (-1 always inlines)
+# Azure OpenAI (provider: azure); baseURL is the resource endpoint, e.g. https://<name>.openai.azure.com
+# azure:
+#   deployment: my-gpt-4o  # defaults to model
+#   apiVersion: 2024-10-21  # default; with openai.api: responses the default is 2025-04-01-preview
+# Response cache: identical requests (provider, model, prompts, schema, sampling, image) skip the
+# network. Bypass with --no-cache; clean up with 'dino cache prune'
+# cache:
+#   disabled: false
+#   dir: ~/.cache/dino/responses  # default: the user cache dir
+# Offline mock provider (provider: mock) for pipeline development; no model needed
+# mock:
+#   fixtures: fixtures  # <path in input>.json, else <image name>.json, returned verbatim; else random boxes
+#   maxBoxes: 5
+#   seed: 0
+#   chunkSize: 16  # characters per streamed chunk
+#   chunkDelay: 20ms
+#   errorRate: 0.1  # fraction of calls that fail
+#   errorStatus: 503  # 0 cuts the stream mid-response instead
+# External process provider (provider: exec): request and response lines are newline-delimited
+# JSON on stdin/stdout, matched by id so --concurrency keeps several requests in flight; see
+# internal/providers/exec.go for the protocol
+# exec:
+#   command: [python3, detector.py]
+#   dir: plugins
+#   env: [DETECTOR_DEVICE=cuda]
+# Retry transient failures (429, 5xx, dropped streams) with jittered exponential backoff
+# retry:
+#   maxAttempts: 3  # total attempts per image; 1 disables retries
+#   initialBackoff: 1s
+#   maxBackoff: 30s  # Retry-After from the server is honored even when longer
+# Client-side pacing so batches stay under provider quotas (0 = unlimited)
+# rateLimit:
+#   rpm: 60  # requests per minute
+#   tpm: 250000  # input tokens per minute, estimated from prompt length and image size
+#   maxInFlight: 4  # concurrent requests
+# HTTP client settings; fallbacks take their own http block
+# http:
+#   timeout: 5m  # whole request; default 2m for gemini/anthropic, none for openai/ollama
+#   proxy: http://proxy.corp:3128  # default honors HTTPS_PROXY/NO_PROXY
+#   caCert: /etc/ssl/corp-ca.pem  # extra trusted CA bundle (PEM)
+#   userAgent: dino/1.0  # replaces the default browser User-Agent
+#   noAPIKeyHeader: true  # openai: send the key only as Authorization, not also as X-Api-Key
+#   headers:
+#     X-Gateway-Tenant: vision
+# Fallbacks tried in order when the provider above fails or its output cannot be parsed
+# fallbacks:
+#   - provider: gemini
+#     model: gemini-2.5-flash
+#     apiKey: your-api-key-here
+#     # baseURL, authType, rateLimit and http can be set per entry as well
+# USD per million tokens, used to estimate cost in the end-of-run usage summary
+# pricing:
+#   - model: gpt-5.1
+#     input: 1.25
+#     output: 10
 input: 'inputs'
-output: 'outputs'
+output: 'outputs'  # json/ detection arrays, bbox/ annotated images, meta/ provider, usage and cost per image
+# outputMeta: true  # or --output-meta; BREAKING for array readers: outputs/json then holds
+#                   # {"provider","model","usage","costUSD","cached","error","detections":[...]}
+# Scanning the input folder; the output folders mirror its subfolders
+# walk:
+#   recursive: true  # or --recursive
+#   include: ['**/*.jpg']  # globs relative to the input; no slash matches the file name anywhere
+#   exclude: ['thumbs']
+#   symlinks: files  # files (default), follow or skip
 # Bbox scale for models that return normalized coordinates (e.g., qwen3-vl uses 1000)
 # Set to 0 or omit for models that return absolute pixel coordinates
 bboxScale: 1000
@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // register GIF for image.Decode
+	"image/jpeg"
+	"image/png"
+	"io"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/ai-is-coming/dino/internal/utils"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+	_ "golang.org/x/image/webp" // register WebP for image.Decode
+)
+
+// sourceImage is an input image decoded once, by content rather than extension.
+type sourceImage struct {
+	img    image.Image
+	format string // name registered with the image package: jpeg, png, gif, webp, bmp or tiff
+	data   []byte // bytes sent to the provider
+}
+
+// decodeImage decodes data and prepares the bytes for the request. Vision APIs take JPEG,
+// PNG, GIF and WebP; BMP and TIFF are re-encoded as PNG.
+//
+// An EXIF orientation is applied to the pixels once and the result re-encoded, so the model
+// and the drawing see the same upright image whether or not the provider honors the tag.
+func decodeImage(data []byte) (sourceImage, error) {
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return sourceImage{}, err
+	}
+
+	src := sourceImage{img: img, format: format, data: data}
+	orientation := utils.ExifOrientation(data)
+
+	switch {
+	case orientation > 1:
+		src.img = utils.ApplyOrientation(img, orientation)
+
+		// Re-encoding also drops the tag, so nothing downstream rotates the image again.
+		enc := pngEncoding
+		if format == "jpeg" {
+			enc = jpegEncoding
+		}
+
+		var buf bytes.Buffer
+		if err := enc.encode(&buf, src.img); err != nil {
+			return sourceImage{}, fmt.Errorf("encode oriented image: %w", err)
+		}
+		src.data = buf.Bytes()
+	case format == "bmp" || format == "tiff":
+		var buf bytes.Buffer
+		if err := png.Encode(&buf, img); err != nil {
+			return sourceImage{}, fmt.Errorf("convert %s to png: %w", format, err)
+		}
+		src.data = buf.Bytes()
+	}
+	return src, nil
+}
+
+// imageEncoding writes annotated images; exts[0] is appended to output names that don't
+// already carry one of exts.
+type imageEncoding struct {
+	exts   []string
+	encode func(io.Writer, image.Image) error
+}
+
+var (
+	jpegEncoding = imageEncoding{[]string{".jpg", ".jpeg"}, func(w io.Writer, img image.Image) error {
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: jpegQuality})
+	}}
+	pngEncoding  = imageEncoding{[]string{".png"}, png.Encode}
+	bmpEncoding  = imageEncoding{[]string{".bmp"}, bmp.Encode}
+	tiffEncoding = imageEncoding{[]string{".tif", ".tiff"}, func(w io.Writer, img image.Image) error {
+		return tiff.Encode(w, img, &tiff.Options{Compression: tiff.Deflate})
+	}}
+)
+
+// annotatedEncoding picks the output encoding for a source format. GIF would dither the
+// boxes into its palette and there is no WebP encoder, so both become PNG.
+func annotatedEncoding(format string) imageEncoding {
+	switch format {
+	case "jpeg":
+		return jpegEncoding
+	case "bmp":
+		return bmpEncoding
+	case "tiff":
+		return tiffEncoding
+	default:
+		return pngEncoding
+	}
+}
+
+// encodeAnnotated encodes img for a source of the given format and returns the output path,
+// with an extension appended when path's doesn't match, e.g. a.webp becomes a.webp.png.
+func encodeAnnotated(img image.Image, format, path string) (string, []byte, error) {
+	enc := annotatedEncoding(format)
+	if !slices.Contains(enc.exts, strings.ToLower(filepath.Ext(path))) {
+		path += enc.exts[0]
+	}
+
+	var buf bytes.Buffer
+	if err := enc.encode(&buf, img); err != nil {
+		return path, nil, err
+	}
+	return path, buf.Bytes(), nil
+}
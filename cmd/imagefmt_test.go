@@ -0,0 +1,113 @@
+package cmd
+
+import (
+	"bytes"
+	"encoding/base64"
+	"image"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"io"
+	"testing"
+
+	"golang.org/x/image/bmp"
+	"golang.org/x/image/tiff"
+)
+
+// webp1x1 is a 1x1 lossless WebP; x/image only decodes WebP, so it can't be generated.
+const webp1x1 = "UklGRhoAAABXRUJQVlA4TA0AAAAvAAAAEAcQERGIiP4HAA=="
+
+// encodeFixture encodes a 4x2 image with enc.
+func encodeFixture(t *testing.T, enc func(io.Writer, image.Image) error) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	if err := enc(&buf, image.NewRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func TestDecodeImage_SniffsFormatAndConvertsUnsupportedOnes(t *testing.T) {
+	webp, err := base64.StdEncoding.DecodeString(webp1x1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		format   string
+		data     []byte
+		width    int
+		sentAsIs bool // the original bytes go to the provider; otherwise they are converted to PNG
+	}{
+		{"jpeg", encodeFixture(t, func(w io.Writer, img image.Image) error { return jpeg.Encode(w, img, nil) }), 4, true},
+		{"png", encodeFixture(t, png.Encode), 4, true},
+		{"gif", encodeFixture(t, func(w io.Writer, img image.Image) error { return gif.Encode(w, img, nil) }), 4, true},
+		{"webp", webp, 1, true},
+		{"bmp", encodeFixture(t, bmp.Encode), 4, false},
+		{"tiff", encodeFixture(t, func(w io.Writer, img image.Image) error { return tiff.Encode(w, img, nil) }), 4, false},
+	}
+
+	for _, c := range cases {
+		t.Run(c.format, func(t *testing.T) {
+			src, err := decodeImage(c.data)
+			if err != nil {
+				t.Fatalf("decodeImage: %v", err)
+			}
+
+			if src.format != c.format || src.img.Bounds().Dx() != c.width {
+				t.Fatalf("format = %s, bounds = %v", src.format, src.img.Bounds())
+			}
+
+			if c.sentAsIs {
+				if !bytes.Equal(src.data, c.data) {
+					t.Fatal("data was re-encoded")
+				}
+				return
+			}
+
+			if _, format, err := image.DecodeConfig(bytes.NewReader(src.data)); err != nil || format != "png" {
+				t.Fatalf("data format = %s, %v; want png", format, err)
+			}
+		})
+	}
+
+	if _, err := decodeImage([]byte("not an image")); err == nil {
+		t.Fatal("expected an error for unknown content")
+	}
+}
+
+func TestEncodeAnnotated_PicksEncodingPerFormat(t *testing.T) {
+	cases := []struct {
+		format, path, wantPath, wantFormat string
+	}{
+		{"jpeg", "out/a.jpg", "out/a.jpg", "jpeg"},
+		{"jpeg", "out/a.JPEG", "out/a.JPEG", "jpeg"},
+		{"jpeg", "out/a.png", "out/a.png.jpg", "jpeg"}, // JPEG content behind a .png name
+		{"png", "out/a.png", "out/a.png", "png"},
+		{"gif", "out/a.gif", "out/a.gif.png", "png"},
+		{"webp", "out/a.webp", "out/a.webp.png", "png"},
+		{"bmp", "out/a.bmp", "out/a.bmp", "bmp"},
+		{"tiff", "out/a.tif", "out/a.tif", "tiff"},
+		{"tiff", "out/a.tiff", "out/a.tiff", "tiff"},
+	}
+
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+
+	for _, c := range cases {
+		path, data, err := encodeAnnotated(img, c.format, c.path)
+		if err != nil {
+			t.Fatalf("%s %s: %v", c.format, c.path, err)
+		}
+
+		if path != c.wantPath {
+			t.Errorf("%s %s: path = %s, want %s", c.format, c.path, path, c.wantPath)
+		}
+
+		cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+		if err != nil || format != c.wantFormat || cfg.Width != 4 || cfg.Height != 2 {
+			t.Errorf("%s %s: encoded %s %dx%d (%v), want %s 4x2",
+				c.format, c.path, format, cfg.Width, cfg.Height, err, c.wantFormat)
+		}
+	}
+}
@@ -0,0 +1,131 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/ai-is-coming/dino/internal/conf"
+	"github.com/ai-is-coming/dino/internal/providers"
+
+	"github.com/fatih/color"
+	"github.com/spf13/cobra"
+)
+
+var modelsVisionOnly bool
+
+// modelsCmd lists the models offered by the configured provider and its fallbacks.
+var modelsCmd = &cobra.Command{
+	Use:   "models",
+	Short: "List models available from the configured providers",
+	Long: "List the models offered by the configured provider and each fallback, using the same " +
+		"credentials and base URL as 'dino run'. Vision support is shown where the API reports it, " +
+		"and the command fails if a configured model does not exist. Providers that cannot list " +
+		"models (azure deployments, mock, exec) are skipped with a note.",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		cfg, err := conf.Load()
+		if err != nil {
+			return fmt.Errorf("load config: %w", err)
+		}
+
+		if strings.TrimSpace(cfg.Provider) == "" || strings.TrimSpace(cfg.Model) == "" {
+			return fmt.Errorf("missing configuration: provider and model are required")
+		}
+
+		var missing []string
+
+		listed := map[string][]providers.ModelInfo{}
+		for _, ep := range append([]conf.Endpoint{cfg.Primary()}, cfg.Fallbacks...) {
+			name := strings.ToLower(strings.TrimSpace(ep.Provider))
+			target := name + "/" + strings.TrimSpace(ep.Model)
+
+			// Endpoints sharing a provider, base URL and key return the same list.
+			key := name + "\x00" + ep.BaseURL + "\x00" + ep.APIKey
+			models, seen := listed[key]
+			if !seen {
+				if models, err = listModels(cmd, cfg, name, ep); errors.Is(err, providers.ErrModelListUnsupported) {
+					color.New(color.FgYellow).Printf("%s: %v; %s not checked\n\n", name, err, target)
+
+					continue
+				} else if err != nil {
+					color.New(color.FgRed).Fprintf(os.Stderr, "%s: %v\n", name, err)
+					missing = append(missing, target+" (list failed)")
+
+					continue
+				}
+				listed[key] = models
+				printModels(name, ep, models)
+			}
+
+			if _, ok := providers.FindModel(models, ep.Model); ok {
+				color.New(color.FgGreen).Printf("configured model %s is available\n\n", target)
+			} else {
+				color.New(color.FgRed).Printf("configured model %s was not found\n\n", target)
+				missing = append(missing, target)
+			}
+		}
+
+		if len(missing) > 0 {
+			return fmt.Errorf("unavailable models: %s", strings.Join(missing, ", "))
+		}
+		return nil
+	},
+}
+
+func attachModelsFlags() {
+	modelsCmd.Flags().BoolVar(&modelsVisionOnly, "vision", false, "only show models reported as vision-capable")
+}
+
+// listModels creates the provider for ep and asks it for its models, sorted by ID.
+func listModels(cmd *cobra.Command, cfg *conf.Config, name string, ep conf.Endpoint) ([]providers.ModelInfo, error) {
+	p, err := providers.New(name, providerConfig(cfg, ep))
+	if err != nil {
+		return nil, err
+	}
+
+	lister, ok := p.(providers.ModelLister)
+	if !ok {
+		return nil, fmt.Errorf("providers/%s: %w", name, providers.ErrModelListUnsupported)
+	}
+
+	models, err := lister.ListModels(cmd.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(models, func(i, j int) bool { return models[i].ID < models[j].ID })
+	return models, nil
+}
+
+// printModels writes one aligned row per model, marking the configured one.
+func printModels(name string, ep conf.Endpoint, models []providers.ModelInfo) {
+	header := name
+	if b := strings.TrimSpace(ep.BaseURL); b != "" {
+		header += " (" + b + ")"
+	}
+	color.New(color.FgCyan, color.Bold).Printf("%s: %d models\n", header, len(models))
+
+	configured, _ := providers.FindModel(models, ep.Model)
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, m := range models {
+		vision := "?"
+		if m.Vision != nil {
+			vision = map[bool]string{true: "vision", false: "text"}[*m.Vision]
+		}
+
+		if modelsVisionOnly && vision != "vision" {
+			continue
+		}
+
+		mark := " "
+		if m.ID == configured.ID {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s %s\t%s\t%s\n", mark, m.ID, vision, m.DisplayName)
+	}
+	_ = w.Flush()
+}
@@ -40,11 +40,15 @@ func Execute() {
 	attachRootFlags()
 	attachRunFlags()
 	attachConfFlags()
+	attachCacheFlags()
+	attachModelsFlags()
 
 	// Register subcommands.
 	rootCmd.AddCommand(versionCmd)
 	rootCmd.AddCommand(runCmd)
 	rootCmd.AddCommand(confCmd)
+	rootCmd.AddCommand(cacheCmd)
+	rootCmd.AddCommand(modelsCmd)
 
 	if err := rootCmd.Execute(); err != nil {
 		color.New(color.FgRed, color.Bold).Fprintln(os.Stderr, err)
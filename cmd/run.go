@@ -3,19 +3,17 @@ package cmd
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"image"
 	"image/color"
-	imagedraw "image/draw"
-	"image/jpeg"
-	"image/png"
 	"io"
 	"os"
 	"path/filepath"
 	"regexp"
-	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/ai-is-coming/dino/internal/conf"
 	"github.com/ai-is-coming/dino/internal/providers"
@@ -23,14 +21,25 @@ import (
 
 	termcolor "github.com/fatih/color"
 	"github.com/kaptinlin/jsonrepair"
-	"github.com/shopspring/decimal"
 	"github.com/spf13/cobra"
 )
 
 var (
-	stream    bool
-	inputDir  string
-	outputDir string
+	stream     bool
+	inputDir   string
+	outputDir  string
+	outputMeta bool
+	recordDir  string
+	replayDir  string
+	noCache    bool
+
+	concurrency int
+	resume      bool
+
+	recursive bool
+	include   []string
+	exclude   []string
+	symlinks  string
 )
 
 var (
@@ -46,6 +55,8 @@ const (
 	bgAlpha       = 200
 	jpegQuality   = 90
 	bboxMinLen    = 4
+
+	tokensPerMillion = 1_000_000
 )
 
 // defaultColorsHex defines a strong-contrast palette. It will be used to cycle-fill
@@ -244,8 +255,8 @@ var runCmd = &cobra.Command{
 			return fmt.Errorf("missing configuration")
 		}
 		termcolor.New(termcolor.FgGreen).Printf(
-			"provider: %s, model: %s, stream: %t, temperature: %s, top_p: %s, bboxScale: %d\n",
-			provider, model, stream, cfg.Temperature, cfg.TopP, cfg.BboxScale,
+			"provider: %s, model: %s, stream: %t, temperature: %s, top_p: %s, think: %t, reasoningEffort: %s, bboxScale: %d\n",
+			provider, model, stream, cfg.Temperature, cfg.TopP, cfg.Think, cfg.ReasoningEffort, cfg.BboxScale,
 		)
 
 		// parse temperature/top_p from string config into float64 with defaults
@@ -277,13 +288,6 @@ var runCmd = &cobra.Command{
 			systemPrompt = bboxHint
 		}
 
-		logPrompts := func(system, user string) {
-			if s := strings.TrimSpace(system); s != "" {
-				termcolor.New(termcolor.FgGreen).Printf("system prompt:\n%s\n\n", s)
-			}
-			termcolor.New(termcolor.FgCyan).Printf("user prompt:\n%s\n\n", user)
-		}
-
 		// Resolve effective input/output and stream from flags vs config
 		effInput := strings.TrimSpace(inputDir)
 		effOutput := strings.TrimSpace(outputDir)
@@ -293,19 +297,36 @@ var runCmd = &cobra.Command{
 		if !cmd.Flags().Changed("output") && strings.TrimSpace(cfg.Output) != "" {
 			effOutput = strings.TrimSpace(cfg.Output)
 		}
+		if !cmd.Flags().Changed("output-meta") {
+			outputMeta = cfg.OutputMeta
+		}
 		if !cmd.Flags().Changed("stream") {
 			stream = cfg.Stream
 
 		}
-		// Initialize provider via factory
-		p, err := providers.New(provider, providers.ProviderConfig{
-			APIKey:   cfg.APIKey,
-			BaseURL:  cfg.BaseURL,
-			AuthType: cfg.AuthType,
-		})
+		workers := concurrency
+		if !cmd.Flags().Changed("concurrency") && cfg.Concurrency > 0 {
+			workers = cfg.Concurrency
+		}
+		workers = max(workers, 1)
+		// Initialize the primary provider and any fallbacks, in priority order
+		routes, err := buildRoutes(cfg)
 		if err != nil {
 			return err
 		}
+		if len(routes) > 1 {
+			names := make([]string, 0, len(routes)-1)
+			for _, rt := range routes[1:] {
+				names = append(names, rt.String())
+			}
+			termcolor.New(termcolor.FgGreen).Printf("fallbacks: %s\n", strings.Join(names, " -> "))
+		}
+		switch {
+		case strings.TrimSpace(replayDir) != "":
+			termcolor.New(termcolor.FgGreen).Printf("replaying responses from %s (offline)\n", replayDir)
+		case strings.TrimSpace(recordDir) != "":
+			termcolor.New(termcolor.FgGreen).Printf("recording responses to %s\n", recordDir)
+		}
 
 		// Batch image mode if an input path is provided
 		if effInput != "" {
@@ -330,9 +351,16 @@ var runCmd = &cobra.Command{
 			if err := os.MkdirAll(jsonDir, permDir); err != nil {
 				return fmt.Errorf("create json output dir: %w", err)
 			}
+			// provider, usage and cost per image go to a sidecar so outputs/json keeps bare arrays
+			metaDir := filepath.Join(effOutput, "meta")
+			if err := os.MkdirAll(metaDir, permDir); err != nil {
+				return fmt.Errorf("create meta output dir: %w", err)
+			}
 
-			// Accept both a directory or a single file path for input
+			// Accept both a directory or a single file path for input. Outputs mirror the
+			// image paths relative to inputRoot.
 			var imgs []string
+			inputRoot := filepath.Dir(effInput)
 			if fi, err := os.Stat(effInput); err == nil {
 				if !fi.IsDir() {
 					// Single file input
@@ -341,20 +369,10 @@ var runCmd = &cobra.Command{
 					}
 				} else {
 					// Directory input
-					entries, err := os.ReadDir(effInput)
-					if err != nil {
-						return fmt.Errorf("read input dir: %w", err)
-					}
-					for _, de := range entries {
-						if de.IsDir() {
-							continue
-						}
-						p := filepath.Join(effInput, de.Name())
-						if utils.IsImageFile(p) {
-							imgs = append(imgs, p)
-						}
+					inputRoot = effInput
+					if imgs, err = utils.FindImages(effInput, walkOptions(cmd, cfg)); err != nil {
+						return err
 					}
-					sort.Strings(imgs)
 				}
 			} else {
 				// Fallback: if Stat failed but the input looks like an image file,
@@ -371,212 +389,38 @@ var runCmd = &cobra.Command{
 				return fmt.Errorf("no images found in %s", effInput)
 			}
 
-			ctx := context.Background()
-			for _, imgPath := range imgs {
-				absImgPath, err := filepath.Abs(imgPath)
-				if err != nil {
-					return fmt.Errorf("failed to get absolute path for %s: %w", imgPath, err)
-				}
-				// Use chat message images instead of appending image path to the prompt
-				currentPrompt := prompt
-				termcolor.New(termcolor.FgCyan).Printf("processing: %s\n", absImgPath)
-				// Load image bytes for Ollama chat images
-				imgBytes, err := os.ReadFile(absImgPath)
-				if err != nil {
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "skip %s: read image: %v\n", absImgPath, err)
-
-					continue
-				}
-
-				// Determine output format from config schema (if provided), else JSON mode
-				format := json.RawMessage(`"json"`)
-				if s := strings.TrimSpace(cfg.Schema); s != "" {
-					if json.Valid([]byte(s)) {
-						format = json.RawMessage(s)
-					} else {
-						termcolor.New(termcolor.FgYellow).Fprintln(
-							os.Stderr,
-							"warn: invalid schema in conf.yaml; falling back to JSON mode",
-						)
-					}
-				}
+			b := &batch{
+				cfg:          cfg,
+				routes:       routes,
+				prompt:       prompt,
+				systemPrompt: systemPrompt,
+				format:       responseFormat(cfg),
+				temp:         temp,
+				topP:         topP,
+				inputRoot:    inputRoot,
+				jsonDir:      jsonDir,
+				metaDir:      metaDir,
+				outputMeta:   outputMeta,
+				bboxDir:      bboxDir,
+				pricing:      newPriceTable(cfg.Pricing),
+				tally:        newUsageTally(),
+				disabled:     make([]error, len(routes)),
+			}
+			b.nameResults(imgs)
+			defer b.tally.print(b.pricing)
 
-				// Build chat options using functional options
-				var sb strings.Builder
-				opts := providers.NewChatOptions(
-					model, currentPrompt,
-					providers.WithStream(stream),
-					providers.WithTemperature(temp),
-					providers.WithTopP(topP),
-					providers.WithImages(imgBytes),
-					providers.WithFormat(format),
-					providers.WithNoResponseFormat(cfg.NoResponseFormat),
-					providers.WithSystemPrompt(systemPrompt),
-					providers.WithOnDelta(func(content, thinking string) error {
-						if stream && thinking != "" {
-							termcolor.New(termcolor.FgHiWhite).Printf("%s", thinking)
-						} else if content != "" {
-							termcolor.New(termcolor.FgHiWhite).Printf("%s", content)
-							sb.WriteString(content)
-						}
-						return nil
-					}),
+			if resume {
+				todo := b.pending(imgs)
+				termcolor.New(termcolor.FgGreen).Printf(
+					"resume: %d of %d images already done, %d to process\n", len(imgs)-len(todo), len(imgs), len(todo),
 				)
-
-				logPrompts(systemPrompt, currentPrompt)
-				if err := p.Chat(ctx, opts); err != nil {
-					termcolor.New(termcolor.FgRed).Fprintf(os.Stderr, "error generating for %s: %v\n", imgPath, err)
-
-					continue
-				}
-
-				out := cleanLLMOutput(sb.String())
-
-				// Attempt to repair invalid JSON (LLM outputs may be malformed)
-				if repaired, err := jsonrepair.JSONRepair(out); err == nil && strings.TrimSpace(repaired) != "" {
-					out = repaired
-				} else if err != nil {
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "warn %s: jsonrepair failed: %v\n", imgPath, err)
-				}
-
-				// Compact JSON output before saving, but keep original if parsing fails.
-				var rawJSON any
-				if err := json.Unmarshal([]byte(out), &rawJSON); err == nil {
-					if compact, err := json.Marshal(rawJSON); err == nil {
-						out = string(compact)
-					}
-				}
-
-				base := filepath.Base(imgPath)
-				ext := filepath.Ext(base)
-				name := strings.TrimSuffix(base, ext)
-
-				// Prepare JSON output path (we will write scaled bbox JSON later)
-				jsonPath := filepath.Join(jsonDir, name+".json")
-
-				// Load and prepare image for drawing
-				imgFile, err := os.Open(imgPath)
-				if err != nil {
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "skip %s: open image: %v\n", base, err)
-
-					continue
-				}
-				img, _, err := image.Decode(imgFile)
-				_ = imgFile.Close()
-				if err != nil {
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "skip %s: decode image: %v\n", base, err)
-
-					continue
-				}
-				bounds := img.Bounds()
-				dst := image.NewRGBA(bounds)
-				imagedraw.Draw(dst, bounds, img, bounds.Min, imagedraw.Src)
-
-				// Parse detections JSON
-				var dets []struct {
-					Label string    `json:"label"`
-					BBox  []float64 `json:"bbox"`
-				}
-				termcolor.New(termcolor.FgHiGreen).Printf("\nassistant response: %s\n", out)
-				if err := json.Unmarshal([]byte(out), &dets); err != nil {
-					// Ensure downstream can read a valid JSON file even if model output is invalid
-					_ = os.WriteFile(jsonPath, []byte("[]"), permFile)
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "skip %s: parse json: %v\n", base, err)
-
-					continue
-				}
-
-				// Build export detections with integer, pixel-space bbox values
-				type exportDet struct {
-					Label string `json:"label"`
-					BBox  []int  `json:"bbox"`
-				}
-				exportDets := make([]exportDet, 0, len(dets))
-
-				for _, d := range dets {
-					label := d.Label
-					x1, y1, x2, y2 := 0, 0, 0, 0
-					if len(d.BBox) >= bboxMinLen {
-						if cfg.BboxScale > 0 {
-							// Expect normalized bbox [x1, y1, x2, y2] in 0..bboxScale (floats or ints)
-							x1, y1, x2, y2 = utils.DenormalizeBbox(
-								strconv.FormatFloat(d.BBox[0], 'f', -1, 64),
-								strconv.FormatFloat(d.BBox[1], 'f', -1, 64),
-								strconv.FormatFloat(d.BBox[2], 'f', -1, 64),
-								strconv.FormatFloat(d.BBox[3], 'f', -1, 64),
-								bounds.Dx(), bounds.Dy(),
-								cfg.BboxScale,
-							)
-						} else {
-							// Expect absolute pixel bbox as floats/ints [x1, y1, x2, y2]
-							dx1 := decimal.NewFromFloat(d.BBox[0])
-							dy1 := decimal.NewFromFloat(d.BBox[1])
-							dx2 := decimal.NewFromFloat(d.BBox[2])
-							dy2 := decimal.NewFromFloat(d.BBox[3])
-							x1 = int(dx1.IntPart())
-							y1 = int(dy1.IntPart())
-							x2 = int(dx2.IntPart())
-							y2 = int(dy2.IntPart())
-						}
-					}
-
-					// normalize and clamp
-					if x1 > x2 {
-						x1, x2 = x2, x1
-					}
-					if y1 > y2 {
-						y1, y2 = y2, y1
-					}
-					x1 = utils.Clamp(x1, bounds.Min.X, bounds.Max.X-1)
-					x2 = utils.Clamp(x2, bounds.Min.X, bounds.Max.X-1)
-					y1 = utils.Clamp(y1, bounds.Min.Y, bounds.Max.Y-1)
-					y2 = utils.Clamp(y2, bounds.Min.Y, bounds.Max.Y-1)
-
-					// Append to export list with scaled bbox
-					exportDets = append(exportDets, exportDet{Label: label, BBox: []int{x1, y1, x2, y2}})
-
-					col := colorForLabel(label, cfg.Classes, cfg.Colors)
-					utils.DrawRect(dst, x1, y1, x2, y2, col, rectThickness)
-					// draw label text on a colored background near the top-left corner of the box
-					bg := color.RGBA{R: col.R, G: col.G, B: col.B, A: bgAlpha}
-					utils.DrawLabel(dst, x1, y1, label, color.RGBA{255, 255, 255, 255}, bg)
-				}
-
-				// Save scaled JSON detections to outputs/json
-				if b, err := json.Marshal(exportDets); err == nil {
-					if err := os.WriteFile(jsonPath, b, permFile); err != nil {
-						termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "warn %s: write json: %v\n", base, err)
-					}
-				} else {
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "warn %s: marshal json: %v\n", base, err)
-				}
-
-				// Save annotated image to outputs/bbox with same base name & extension
-				outImgPath := filepath.Join(bboxDir, base)
-				outFile, err := os.Create(outImgPath)
-				if err != nil {
-					termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "skip %s: create out: %v\n", base, err)
-
-					continue
-				}
-				switch strings.ToLower(ext) {
-				case ".jpg", ".jpeg":
-					_ = jpeg.Encode(outFile, dst, &jpeg.Options{Quality: jpegQuality})
-				case ".png":
-					_ = png.Encode(outFile, dst)
-				default:
-					// Fallback to PNG if format not directly supported
-					_ = outFile.Close()
-					outImgPath = filepath.Join(bboxDir, name+".png")
-					outFile, err = os.Create(outImgPath)
-					if err == nil {
-						_ = png.Encode(outFile, dst)
-					}
-				}
-				_ = outFile.Close()
-				termcolor.New(termcolor.FgGreen).Printf("saved %s\n\n", outImgPath)
+				imgs = todo
 			}
-			return nil
+			if workers > 1 {
+				termcolor.New(termcolor.FgGreen).Printf("processing %d images, %d at a time\n", len(imgs), workers)
+			}
+
+			return b.run(context.Background(), imgs, workers)
 		}
 
 		// Fallback to original text prompt mode (no input directory)
@@ -585,18 +429,7 @@ var runCmd = &cobra.Command{
 			return err
 		}
 
-		// Determine output format from config schema (if provided), else JSON mode
-		format := json.RawMessage(`"json"`)
-		if s := strings.TrimSpace(cfg.Schema); s != "" {
-			if json.Valid([]byte(s)) {
-				format = json.RawMessage(s)
-			} else {
-				termcolor.New(termcolor.FgYellow).Fprintln(
-					os.Stderr,
-					"warn: invalid schema in conf.yaml; falling back to JSON mode",
-				)
-			}
-		}
+		format := responseFormat(cfg)
 
 		ctx := context.Background()
 		if stream {
@@ -606,6 +439,10 @@ var runCmd = &cobra.Command{
 				providers.WithStream(true),
 				providers.WithTemperature(temp),
 				providers.WithTopP(topP),
+				providers.WithThink(cfg.Think),
+				providers.WithReasoningEffort(cfg.ReasoningEffort),
+				providers.WithThinkingBudget(cfg.ThinkingBudget),
+				outputControls(cfg),
 				providers.WithFormat(format),
 				providers.WithNoResponseFormat(cfg.NoResponseFormat),
 				providers.WithSystemPrompt(systemPrompt),
@@ -615,9 +452,10 @@ var runCmd = &cobra.Command{
 					}
 					return nil
 				}),
+				providers.WithOnRetry(retryLogger(os.Stderr)),
 			)
-			logPrompts(systemPrompt, prompt)
-			if err := p.Chat(ctx, opts); err != nil {
+			logPrompts(os.Stdout, systemPrompt, prompt)
+			if err := chatWithFallback(ctx, routes, opts); err != nil {
 				return err
 			}
 			fmt.Println()
@@ -630,6 +468,10 @@ var runCmd = &cobra.Command{
 			providers.WithStream(false),
 			providers.WithTemperature(temp),
 			providers.WithTopP(topP),
+			providers.WithThink(cfg.Think),
+			providers.WithReasoningEffort(cfg.ReasoningEffort),
+			providers.WithThinkingBudget(cfg.ThinkingBudget),
+			outputControls(cfg),
 			providers.WithFormat(format),
 			providers.WithNoResponseFormat(cfg.NoResponseFormat),
 			providers.WithSystemPrompt(systemPrompt),
@@ -639,12 +481,10 @@ var runCmd = &cobra.Command{
 				}
 				return nil
 			}),
+			providers.WithOnRetry(retryLogger(os.Stderr)),
 		)
-		logPrompts(systemPrompt, prompt)
-		if err := p.Chat(ctx, opts); err != nil {
-			return err
-		}
-		return nil
+		logPrompts(os.Stdout, systemPrompt, prompt)
+		return chatWithFallback(ctx, routes, opts)
 	},
 }
 
@@ -652,6 +492,478 @@ func attachRunFlags() {
 	runCmd.Flags().BoolVar(&stream, "stream", true, "stream responses (ollama)")
 	runCmd.Flags().StringVarP(&inputDir, "input", "i", "", "input folder containing images")
 	runCmd.Flags().StringVarP(&outputDir, "output", "o", "", "output folder to save results")
+	runCmd.Flags().BoolVar(&outputMeta, "output-meta", false,
+		"write outputs/json as objects with provider, model, usage and detections instead of bare arrays")
+	runCmd.Flags().StringVar(&recordDir, "record", "", "save every provider response to this folder for later --replay")
+	runCmd.Flags().StringVar(&replayDir, "replay", "",
+		"serve provider responses from a --record folder without network access")
+	runCmd.MarkFlagsMutuallyExclusive("record", "replay")
+	runCmd.Flags().BoolVar(&noCache, "no-cache", false, "always query the provider instead of reusing cached responses")
+	runCmd.Flags().IntVar(&concurrency, "concurrency", 1, "number of images processed in parallel")
+	runCmd.Flags().BoolVar(&resume, "resume", false, "skip images whose result was saved with the same settings")
+	runCmd.Flags().BoolVarP(&recursive, "recursive", "r", false, "also process images in subfolders of the input")
+	runCmd.Flags().StringSliceVar(&include, "include", nil, "only process images matching these globs, e.g. '**/*.jpg'")
+	runCmd.Flags().StringSliceVar(&exclude, "exclude", nil, "skip files and folders matching these globs")
+	runCmd.Flags().StringVar(&symlinks, "symlinks", "", "symlink policy: files (default), follow or skip")
+}
+
+// walkOptions resolves the input scan settings; flags override the config file.
+func walkOptions(cmd *cobra.Command, cfg *conf.Config) utils.WalkOptions {
+	opts := utils.WalkOptions{
+		Recursive: cfg.Walk.Recursive,
+		Include:   cfg.Walk.Include,
+		Exclude:   cfg.Walk.Exclude,
+		Symlinks:  strings.ToLower(strings.TrimSpace(cfg.Walk.Symlinks)),
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("recursive") {
+		opts.Recursive = recursive
+	}
+	if flags.Changed("include") {
+		opts.Include = include
+	}
+	if flags.Changed("exclude") {
+		opts.Exclude = exclude
+	}
+	if flags.Changed("symlinks") {
+		opts.Symlinks = strings.ToLower(strings.TrimSpace(symlinks))
+	}
+	return opts
+}
+
+// providerConfig maps the loaded configuration onto the provider factory settings.
+// Endpoint and credentials come from ep; provider-specific sections are shared by all routes.
+func providerConfig(cfg *conf.Config, ep conf.Endpoint) providers.ProviderConfig {
+	return providers.ProviderConfig{
+		APIKey:   ep.APIKey,
+		BaseURL:  ep.BaseURL,
+		AuthType: ep.AuthType,
+		HTTP: providers.HTTPConfig{
+			Timeout:        ep.HTTP.Timeout,
+			Proxy:          ep.HTTP.Proxy,
+			CACert:         ep.HTTP.CACert,
+			Headers:        ep.HTTP.Headers,
+			UserAgent:      ep.HTTP.UserAgent,
+			NoAPIKeyHeader: ep.HTTP.NoAPIKeyHeader,
+		},
+		Ollama: providers.OllamaConfig{
+			KeepAlive:  cfg.Ollama.KeepAlive,
+			NumCtx:     cfg.Ollama.NumCtx,
+			NumPredict: cfg.Ollama.NumPredict,
+		},
+		OpenAI: providers.OpenAIConfig{API: cfg.OpenAI.API},
+		Azure: providers.AzureConfig{
+			Deployment: azureDeployment(cfg, ep),
+			APIVersion: cfg.Azure.APIVersion,
+		},
+		Gemini: providers.GeminiConfig{
+			SafetySettings:  geminiSafetySettings(cfg),
+			UploadThreshold: cfg.Gemini.UploadThreshold,
+		},
+		Mock: providers.MockConfig{
+			Fixtures:    cfg.Mock.Fixtures,
+			Classes:     cfg.Classes,
+			BboxScale:   cfg.BboxScale,
+			MaxBoxes:    cfg.Mock.MaxBoxes,
+			Seed:        cfg.Mock.Seed,
+			ChunkSize:   cfg.Mock.ChunkSize,
+			ChunkDelay:  cfg.Mock.ChunkDelay,
+			ErrorRate:   cfg.Mock.ErrorRate,
+			ErrorStatus: cfg.Mock.ErrorStatus,
+		},
+		Exec: providers.ExecConfig{
+			Command: cfg.Exec.Command,
+			Dir:     cfg.Exec.Dir,
+			Env:     cfg.Exec.Env,
+		},
+	}
+}
+
+// geminiSafetySettings converts the configured thresholds, skipping incomplete entries.
+func geminiSafetySettings(cfg *conf.Config) []providers.GeminiSafetySetting {
+	var out []providers.GeminiSafetySetting
+
+	for _, s := range cfg.Gemini.SafetySettings {
+		category, threshold := strings.TrimSpace(s.Category), strings.TrimSpace(s.Threshold)
+		if category == "" || threshold == "" {
+			continue
+		}
+		out = append(out, providers.GeminiSafetySetting{Category: category, Threshold: threshold})
+	}
+	return out
+}
+
+// azureDeployment returns the configured deployment for the primary model; other models,
+// such as fallbacks, are deployed under their own name.
+func azureDeployment(cfg *conf.Config, ep conf.Endpoint) string {
+	if cfg.Azure.Deployment != "" && ep.Model == cfg.Model {
+		return cfg.Azure.Deployment
+	}
+	return ep.Model
+}
+
+// buildRoutes constructs the primary provider followed by the configured fallbacks, each
+// wrapped with its own rate limit and the shared retry policy. With --replay every route is
+// served from the recordings instead, so no provider client is created at all.
+func buildRoutes(cfg *conf.Config) ([]providers.Route, error) {
+	endpoints := append([]conf.Endpoint{cfg.Primary()}, cfg.Fallbacks...)
+	routes := make([]providers.Route, 0, len(endpoints))
+
+	var (
+		replayer providers.Provider
+		cache    *providers.ResponseCache
+		err      error
+	)
+	if dir := strings.TrimSpace(replayDir); dir != "" {
+		if replayer, err = providers.NewReplayer(dir); err != nil {
+			return nil, err
+		}
+	} else if !noCache && !cfg.Cache.Disabled {
+		if cache, err = openResponseCache(cfg); err != nil {
+			return nil, err
+		}
+	}
+
+	for i, ep := range endpoints {
+		name := strings.ToLower(strings.TrimSpace(ep.Provider))
+		model := strings.TrimSpace(ep.Model)
+		if name == "" || model == "" { // the primary is validated by the caller
+			return nil, fmt.Errorf("fallbacks[%d]: provider and model are required", i-1)
+		}
+
+		if replayer != nil {
+			routes = append(routes, providers.Route{Name: name, Model: model, Provider: replayer})
+			continue
+		}
+
+		p, err := newRouteProvider(cfg, name, ep, cache)
+		if err != nil {
+			return nil, fmt.Errorf("%s/%s: %w", name, model, err)
+		}
+
+		routes = append(routes, providers.Route{Name: name, Model: model, Provider: p})
+	}
+	return routes, nil
+}
+
+// newRouteProvider creates the provider for ep with rate limiting and retries, behind the
+// response cache when one is given. With --record a recorder wraps the whole stack so only
+// final responses are stored.
+func newRouteProvider(
+	cfg *conf.Config, name string, ep conf.Endpoint, cache *providers.ResponseCache,
+) (providers.Provider, error) {
+	pc := providerConfig(cfg, ep)
+
+	p, err := providers.New(name, pc)
+	if err != nil {
+		return nil, err
+	}
+	p = providers.WithRateLimit(p, name, providers.RateLimit{
+		RPM:         ep.RateLimit.RPM,
+		TPM:         ep.RateLimit.TPM,
+		MaxInFlight: ep.RateLimit.MaxInFlight,
+	})
+	p = providers.WithRetry(p, providers.RetryPolicy{
+		MaxAttempts:    cfg.Retry.MaxAttempts,
+		InitialBackoff: cfg.Retry.InitialBackoff,
+		MaxBackoff:     cfg.Retry.MaxBackoff,
+	})
+	// The mock answers instantly and may inject errors on purpose, so caching it hides nothing useful.
+	if name != "mock" {
+		p = providers.WithCache(p, name, pc, cache)
+	}
+
+	if dir := strings.TrimSpace(recordDir); dir != "" {
+		return providers.WithRecorder(p, dir)
+	}
+	return p, nil
+}
+
+// openResponseCache opens the configured cache directory, or the per-user default. A leading
+// ~/ stands for the home directory.
+func openResponseCache(cfg *conf.Config) (*providers.ResponseCache, error) {
+	dir := strings.TrimSpace(cfg.Cache.Dir)
+	if dir == "" {
+		var err error
+		if dir, err = providers.DefaultCacheDir(); err != nil {
+			return nil, err
+		}
+	}
+
+	if dir == "~" || strings.HasPrefix(dir, "~/") {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("cache dir %s: %w", dir, err)
+		}
+		dir = filepath.Join(home, dir[1:])
+	}
+	return providers.NewResponseCache(dir)
+}
+
+// outputControls applies the configured token cap, seed, stop sequences and image detail.
+func outputControls(cfg *conf.Config) providers.Option {
+	return func(o *providers.ChatOptions) {
+		providers.WithMaxTokens(cfg.MaxTokens)(o)
+		providers.WithStop(cfg.Stop...)(o)
+		providers.WithImageDetail(cfg.ImageDetail)(o)
+
+		if cfg.Seed != nil {
+			providers.WithSeed(*cfg.Seed)(o)
+		}
+	}
+}
+
+// routeUnusable reports whether err means the route fails for every image, such as a
+// rejected key or an unknown model.
+func routeUnusable(err error) bool {
+	return errors.Is(err, providers.ErrAuth) || errors.Is(err, providers.ErrModelNotFound)
+}
+
+// retryLater reports whether an image that failed on every route may succeed on a later pass.
+func retryLater(err error) bool {
+	var pErr *providers.Error
+	return errors.As(err, &pErr) && pErr.Retryable()
+}
+
+// chatWithFallback sends opts to each route in order until one succeeds. Output a failed
+// route already printed stays on screen, so it is marked as discarded before the next route.
+func chatWithFallback(ctx context.Context, routes []providers.Route, opts providers.ChatOptions) error {
+	var (
+		err     error
+		printed bool
+	)
+	onDelta := opts.OnDelta
+	opts.OnDelta = func(content, thinking string) error {
+		printed = printed || content != ""
+		if onDelta == nil {
+			return nil
+		}
+		return onDelta(content, thinking)
+	}
+
+	for i, rt := range routes {
+		if i > 0 {
+			if printed {
+				printDiscarded(os.Stdout)
+				printed = false
+			}
+			termcolor.New(termcolor.FgYellow).Fprintf(os.Stderr, "%v; falling back to %s\n", err, rt)
+		}
+
+		opts.Model = rt.Model
+		_, err = rt.Provider.Chat(ctx, opts)
+		if err = providers.Classify(rt.Name, err); err == nil || ctx.Err() != nil {
+			return err
+		}
+	}
+	return err
+}
+
+// printDiscarded rules off streamed output from a route that failed, so it is not read as
+// part of the answer that follows.
+func printDiscarded(w io.Writer) {
+	termcolor.New(termcolor.FgYellow).Fprintln(w, "\n--- output above is from a failed route and is discarded ---")
+}
+
+// detection is a single model-reported box before scaling to pixel space.
+type detection struct {
+	Label string    `json:"label"`
+	BBox  []float64 `json:"bbox"`
+}
+
+// exportDet is a detection with integer, pixel-space bbox values.
+type exportDet struct {
+	Label string `json:"label"`
+	BBox  []int  `json:"bbox"`
+}
+
+// imageMeta is the per-image sidecar written to outputs/meta. The detections themselves stay
+// a bare array in outputs/json. Usage and cost cover every attempt made for the image,
+// including fallbacks.
+type imageMeta struct {
+	Provider string           `json:"provider,omitempty"`
+	Model    string           `json:"model,omitempty"`
+	Usage    *providers.Usage `json:"usage,omitempty"`
+	CostUSD  float64          `json:"costUSD,omitempty"`
+	Cached   bool             `json:"cached,omitempty"` // served from the response cache or a replay
+	Error    string           `json:"error,omitempty"`
+
+	// Fingerprint identifies the configuration that produced the result; see --resume.
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// imageResult is the outputs/json layout with --output-meta: the sidecar fields followed by
+// the detections. Without it outputs/json keeps bare detection arrays.
+type imageResult struct {
+	imageMeta
+
+	Detections []exportDet `json:"detections"`
+}
+
+func usageOrNil(u providers.Usage) *providers.Usage {
+	if u.IsZero() {
+		return nil
+	}
+	return &u
+}
+
+// priceTable maps model names to USD prices per million tokens.
+type priceTable map[string]conf.ModelPrice
+
+func newPriceTable(prices []conf.ModelPrice) priceTable {
+	t := make(priceTable, len(prices))
+	for _, p := range prices {
+		t[strings.TrimSpace(p.Model)] = p
+	}
+	return t
+}
+
+// cost estimates the USD cost of u on model; thinking tokens are billed at the output rate.
+func (t priceTable) cost(model string, u providers.Usage) float64 {
+	p, ok := t[model]
+	if !ok {
+		return 0
+	}
+	return (float64(u.PromptTokens)*p.Input + float64(u.OutputTokens())*p.Output) / tokensPerMillion
+}
+
+// usageTally accumulates token usage per route for the end-of-run summary.
+type usageTally struct {
+	mu     sync.Mutex
+	order  []providers.Route
+	byName map[string]*providers.Usage
+	cached int // responses served from the cache or a replay
+}
+
+func newUsageTally() *usageTally {
+	return &usageTally{byName: map[string]*providers.Usage{}}
+}
+
+func (t *usageTally) add(rt providers.Route, u providers.Usage) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	key := rt.String()
+	if _, ok := t.byName[key]; !ok {
+		t.order = append(t.order, rt)
+		t.byName[key] = &providers.Usage{}
+	}
+	t.byName[key].Add(u)
+	if u.Cached {
+		t.cached++
+	}
+}
+
+func (t *usageTally) print(pricing priceTable) {
+	var (
+		total providers.Usage
+		cost  float64
+	)
+	for _, rt := range t.order {
+		u := *t.byName[rt.String()]
+		total.Add(u)
+		cost += pricing.cost(rt.Model, u)
+		if len(t.order) > 1 {
+			termcolor.New(termcolor.FgHiBlack).Printf("usage %s: %s\n", rt, formatUsage(u))
+		}
+	}
+	if t.cached > 0 {
+		termcolor.New(termcolor.FgHiBlack).Printf("cached responses: %d\n", t.cached)
+	}
+	if total.IsZero() {
+		return
+	}
+
+	line := "total usage: " + formatUsage(total)
+	if cost > 0 {
+		line += fmt.Sprintf(", estimated cost: $%.4f", cost)
+	}
+	termcolor.New(termcolor.FgGreen, termcolor.Bold).Println(line)
+}
+
+func formatUsage(u providers.Usage) string {
+	return fmt.Sprintf(
+		"prompt %d (images %d), completion %d, thinking %d tokens",
+		u.PromptTokens, u.ImageTokens, u.CompletionTokens, u.ThinkingTokens,
+	)
+}
+
+// writeJSONFile saves v to path atomically, reporting failures to w.
+func writeJSONFile(w io.Writer, path string, v any) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(w, "warn %s: marshal json: %v\n", path, err)
+		return
+	}
+	if err := os.MkdirAll(filepath.Dir(path), permDir); err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(w, "warn %s: create dir: %v\n", path, err)
+		return
+	}
+	if err := utils.WriteFileAtomic(path, b, permFile); err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(w, "warn %s: write json: %v\n", path, err)
+	}
+}
+
+// repairLLMOutput cleans wrappers, repairs malformed JSON and compacts it when possible.
+// Repair failures are reported to w.
+func repairLLMOutput(w io.Writer, imgPath, raw string) string {
+	out := cleanLLMOutput(raw)
+
+	// Attempt to repair invalid JSON (LLM outputs may be malformed)
+	if repaired, err := jsonrepair.JSONRepair(out); err == nil && strings.TrimSpace(repaired) != "" {
+		out = repaired
+	} else if err != nil {
+		termcolor.New(termcolor.FgYellow).Fprintf(w, "warn %s: jsonrepair failed: %v\n", imgPath, err)
+	}
+
+	// Compact JSON output before saving, but keep original if parsing fails.
+	var rawJSON any
+	if err := json.Unmarshal([]byte(out), &rawJSON); err == nil {
+		if compact, err := json.Marshal(rawJSON); err == nil {
+			out = string(compact)
+		}
+	}
+	return out
+}
+
+// parseDetections decodes a JSON array of detections.
+func parseDetections(out string) ([]detection, error) {
+	var dets []detection
+	if err := json.Unmarshal([]byte(out), &dets); err != nil {
+		return nil, err
+	}
+	return dets, nil
+}
+
+// responseFormat returns the configured schema, or JSON mode when none is set or it is invalid.
+func responseFormat(cfg *conf.Config) json.RawMessage {
+	s := strings.TrimSpace(cfg.Schema)
+	if s == "" {
+		return json.RawMessage(`"json"`)
+	}
+
+	if !json.Valid([]byte(s)) {
+		termcolor.New(termcolor.FgYellow).Fprintln(os.Stderr, "warn: invalid schema in conf.yaml; falling back to JSON mode")
+		return json.RawMessage(`"json"`)
+	}
+	return json.RawMessage(s)
+}
+
+func logPrompts(w io.Writer, system, user string) {
+	if s := strings.TrimSpace(system); s != "" {
+		termcolor.New(termcolor.FgGreen).Fprintf(w, "system prompt:\n%s\n\n", s)
+	}
+	termcolor.New(termcolor.FgCyan).Fprintf(w, "user prompt:\n%s\n\n", user)
+}
+
+// retryLogger returns an OnRetry callback that reports to w.
+func retryLogger(w io.Writer) func(int, error, time.Duration) {
+	return func(attempt int, err error, wait time.Duration) {
+		termcolor.New(termcolor.FgYellow).Fprintf(
+			w, "\nattempt %d failed: %v; retrying in %s\n", attempt, err, wait.Round(time.Millisecond),
+		)
+	}
 }
 
 func buildPrompt(args []string) (string, error) {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	"github.com/knadh/koanf/parsers/yaml"
 	"github.com/knadh/koanf/providers/env"
@@ -20,6 +21,7 @@ type Config struct {
 	Stream           bool     `koanf:"stream"`
 	Input            string   `koanf:"input"`
 	Output           string   `koanf:"output"`
+	OutputMeta       bool     `koanf:"outputMeta"` // outputs/json holds objects with provider, usage and detections
 	Classes          []string `koanf:"classes"`
 	Colors           []string `koanf:"colors"`
 	Prompt           string   `koanf:"prompt"`
@@ -27,11 +29,154 @@ type Config struct {
 	NoResponseFormat bool     `koanf:"noResponseFormat"`
 	Temperature      string   `koanf:"temperature"`
 	TopP             string   `koanf:"topP"`
+	Think            bool     `koanf:"think"`           // enable reasoning/thinking output where supported
+	ReasoningEffort  string   `koanf:"reasoningEffort"` // none, minimal, low, medium, high
+	ThinkingBudget   int      `koanf:"thinkingBudget"`  // reasoning token budget (gemini/anthropic); 0 derives from effort
+	MaxTokens        int      `koanf:"maxTokens"`       // cap on generated tokens; 0 = provider default
+	Seed             *int64   `koanf:"seed"`            // sampling seed for reproducible runs (openai, gemini, ollama)
+	Stop             []string `koanf:"stop"`            // sequences that end generation
+	ImageDetail      string   `koanf:"imageDetail"`     // openai image detail: auto (default), low, high
+	Concurrency      int      `koanf:"concurrency"`     // images processed in parallel; --concurrency overrides
 	Schema           string   `koanf:"schema"`
 	APIKey           string   `koanf:"apiKey"`
 	BaseURL          string   `koanf:"baseURL"`
-	AuthType         string   `koanf:"authType"`  // "api_key" (default) or "auth_token"
+	AuthType         string   `koanf:"authType"`  // "api_key" or "auth_token"; ollama defaults to "auth_token"
 	BboxScale        int      `koanf:"bboxScale"` // Scale for bbox normalization (e.g., 1000); 0 means no denormalization
+
+	Walk      WalkConfig      `koanf:"walk"`
+	Ollama    OllamaConfig    `koanf:"ollama"`
+	OpenAI    OpenAIConfig    `koanf:"openai"`
+	Azure     AzureConfig     `koanf:"azure"`
+	Gemini    GeminiConfig    `koanf:"gemini"`
+	Mock      MockConfig      `koanf:"mock"`
+	Exec      ExecConfig      `koanf:"exec"`
+	Cache     CacheConfig     `koanf:"cache"`
+	Retry     RetryConfig     `koanf:"retry"`
+	RateLimit RateLimitConfig `koanf:"rateLimit"`
+	HTTP      HTTPConfig      `koanf:"http"`
+
+	// Pricing lists USD prices per million tokens used to estimate run cost.
+	Pricing []ModelPrice `koanf:"pricing"`
+
+	// Fallbacks are tried in order when the primary provider fails or returns unparseable output.
+	Fallbacks []Endpoint `koanf:"fallbacks"`
+}
+
+// ModelPrice is the USD price per million tokens for a model. Thinking tokens use Output.
+type ModelPrice struct {
+	Model  string  `koanf:"model"`
+	Input  float64 `koanf:"input"`
+	Output float64 `koanf:"output"`
+}
+
+// Endpoint identifies one provider/model target with its own credentials and pacing.
+type Endpoint struct {
+	Provider  string          `koanf:"provider"`
+	Model     string          `koanf:"model"`
+	APIKey    string          `koanf:"apiKey"`
+	BaseURL   string          `koanf:"baseURL"`
+	AuthType  string          `koanf:"authType"`
+	RateLimit RateLimitConfig `koanf:"rateLimit"`
+	HTTP      HTTPConfig      `koanf:"http"`
+}
+
+// Primary returns the top-level provider settings as an Endpoint.
+func (c *Config) Primary() Endpoint {
+	return Endpoint{
+		Provider:  c.Provider,
+		Model:     c.Model,
+		APIKey:    c.APIKey,
+		BaseURL:   c.BaseURL,
+		AuthType:  c.AuthType,
+		RateLimit: c.RateLimit,
+		HTTP:      c.HTTP,
+	}
+}
+
+// WalkConfig controls how an input folder is scanned for images.
+type WalkConfig struct {
+	Recursive bool     `koanf:"recursive"` // descend into subfolders
+	Include   []string `koanf:"include"`   // globs relative to the input, e.g. "**/*.jpg"; empty takes every image
+	Exclude   []string `koanf:"exclude"`   // globs for files or folders to skip, e.g. "thumbs"
+	Symlinks  string   `koanf:"symlinks"`  // files (default), follow or skip
+}
+
+// RateLimitConfig paces requests to the configured provider; zero values are unlimited.
+type RateLimitConfig struct {
+	RPM         int `koanf:"rpm"`         // requests per minute
+	TPM         int `koanf:"tpm"`         // estimated input tokens per minute
+	MaxInFlight int `koanf:"maxInFlight"` // concurrent requests
+}
+
+// HTTPConfig customizes the HTTP client used for one provider.
+type HTTPConfig struct {
+	Timeout        time.Duration     `koanf:"timeout"`        // e.g. "5m"; 0 = provider default, negative disables
+	Proxy          string            `koanf:"proxy"`          // e.g. "http://proxy.corp:3128"; default honors HTTPS_PROXY
+	CACert         string            `koanf:"caCert"`         // PEM bundle trusted in addition to the system roots
+	Headers        map[string]string `koanf:"headers"`        // extra headers sent with every request
+	UserAgent      string            `koanf:"userAgent"`      // replaces the default browser User-Agent
+	NoAPIKeyHeader bool              `koanf:"noAPIKeyHeader"` // openai: don't duplicate the key into X-Api-Key
+}
+
+// RetryConfig controls retries of transient provider failures (429, 5xx, cut streams).
+type RetryConfig struct {
+	MaxAttempts    int           `koanf:"maxAttempts"`    // total attempts per request; 0 = default (3), 1 disables
+	InitialBackoff time.Duration `koanf:"initialBackoff"` // e.g. "1s"; doubles per retry with jitter
+	MaxBackoff     time.Duration `koanf:"maxBackoff"`     // cap for computed delays; Retry-After may exceed it
+}
+
+// OllamaConfig holds settings only used by the ollama provider.
+type OllamaConfig struct {
+	KeepAlive  string `koanf:"keepAlive"`  // e.g. "5m", or seconds; "-1" keeps the model loaded
+	NumCtx     int    `koanf:"numCtx"`     // context window (num_ctx)
+	NumPredict int    `koanf:"numPredict"` // max generated tokens (num_predict)
+}
+
+// OpenAIConfig holds settings only used by the openai provider.
+type OpenAIConfig struct {
+	API string `koanf:"api"` // "chat" (default) or "responses" to use /v1/responses
+}
+
+// AzureConfig selects an Azure OpenAI deployment; baseURL is the resource endpoint.
+type AzureConfig struct {
+	Deployment string `koanf:"deployment"` // defaults to the model name
+	APIVersion string `koanf:"apiVersion"` // e.g. "2024-10-21"
+}
+
+// GeminiConfig holds settings only used by the gemini provider.
+type GeminiConfig struct {
+	SafetySettings  []SafetySetting `koanf:"safetySettings"`
+	UploadThreshold int64           `koanf:"uploadThreshold"` // bytes above which images use the Files API; 0 = 10 MiB
+}
+
+// SafetySetting sets the block threshold for one Gemini harm category.
+type SafetySetting struct {
+	Category  string `koanf:"category"`  // e.g. HARM_CATEGORY_DANGEROUS_CONTENT
+	Threshold string `koanf:"threshold"` // e.g. BLOCK_ONLY_HIGH, BLOCK_NONE
+}
+
+// CacheConfig controls the on-disk response cache.
+type CacheConfig struct {
+	Disabled bool   `koanf:"disabled"` // same as --no-cache
+	Dir      string `koanf:"dir"`      // defaults to the user cache dir, e.g. ~/.cache/dino/responses
+}
+
+// MockConfig controls the offline mock provider; boxes use classes and bboxScale.
+type MockConfig struct {
+	Fixtures    string        `koanf:"fixtures"`    // folder of <image name>.json responses
+	MaxBoxes    int           `koanf:"maxBoxes"`    // max generated boxes per image (default 5)
+	Seed        uint64        `koanf:"seed"`        // change to get a different set of random boxes
+	ChunkSize   int           `koanf:"chunkSize"`   // characters per streamed chunk (default 16)
+	ChunkDelay  time.Duration `koanf:"chunkDelay"`  // e.g. "20ms" between streamed chunks
+	ErrorRate   float64       `koanf:"errorRate"`   // fraction of calls that fail, 0-1
+	ErrorStatus int           `koanf:"errorStatus"` // HTTP status of injected failures; 0 cuts the stream
+}
+
+// ExecConfig runs an external process as the provider (provider: exec).
+type ExecConfig struct {
+	Command []string `koanf:"command"` // program and arguments, e.g. ["python3", "detector.py"]
+	Dir     string   `koanf:"dir"`     // working directory
+	Env     []string `koanf:"env"`     // extra KEY=VALUE environment entries
 }
 
 // Init initializes the configuration from file and environment variables.
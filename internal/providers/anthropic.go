@@ -0,0 +1,587 @@
+package providers
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	defaultAnthropicBaseURL    = "https://api.anthropic.com"
+	anthropicMessagesPath      = "/v1/messages"
+	anthropicVersion           = "2023-06-01"
+	anthropicHTTPTimeout       = 2 * time.Minute // default; see HTTPConfig.Timeout
+	anthropicDefaultMaxTokens  = 4096
+	anthropicMinThinkingBudget = 1024
+	anthropicStatusOverloaded  = 529
+)
+
+// Anthropic implements the Provider interface using the Anthropic Messages API.
+type Anthropic struct {
+	client    *http.Client
+	endpoint  string
+	apiKey    string
+	authType  string
+	userAgent string
+}
+
+// NewAnthropic constructs an Anthropic provider using the provided configuration.
+func NewAnthropic(cfg ProviderConfig) (*Anthropic, error) {
+	apiKey := strings.TrimSpace(cfg.APIKey)
+	if apiKey == "" {
+		return nil, fmt.Errorf("providers/anthropic: missing api key")
+	}
+
+	authType := strings.ToLower(strings.TrimSpace(cfg.AuthType))
+	if authType == "" {
+		authType = "api_key"
+	}
+
+	if authType != "api_key" && authType != "auth_token" {
+		return nil, fmt.Errorf("providers/anthropic: unsupported auth type %q", cfg.AuthType)
+	}
+
+	client, err := cfg.HTTP.client(anthropicHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Anthropic{
+		client:    client,
+		endpoint:  anthropicEndpoint(cfg.BaseURL),
+		apiKey:    apiKey,
+		authType:  authType,
+		userAgent: cfg.HTTP.userAgent(),
+	}, nil
+}
+
+// anthropicEndpoint resolves the messages endpoint, accepting bases with or without a trailing /v1.
+func anthropicEndpoint(base string) string {
+	b := strings.TrimRight(strings.TrimSpace(base), "/")
+	if b == "" {
+		b = defaultAnthropicBaseURL
+	}
+
+	if strings.HasSuffix(b, "/v1") {
+		return b + "/messages"
+	}
+	return b + anthropicMessagesPath
+}
+
+// Chat sends a user prompt (with optional images) to the Messages API and streams responses if requested.
+// Thinking blocks are forwarded through the thinking argument of OnDelta. A response cut off by
+// max_tokens or refused by the model returns an *AnthropicStopError after its text is emitted.
+//
+// opts.Format is not sent: the Messages API has no JSON mode or response schema, so the
+// prompt has to ask for JSON and callers repair and validate the answer.
+func (a *Anthropic) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	if a == nil || a.client == nil {
+		return Usage{}, fmt.Errorf("providers/anthropic: nil client")
+	}
+
+	if strings.TrimSpace(opts.Model) == "" {
+		return Usage{}, fmt.Errorf("providers/anthropic: model is required")
+	}
+
+	body, err := buildAnthropicRequestBody(opts)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	req, err := a.newRequest(ctx, http.MethodPost, a.endpoint, body, opts.Stream)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return Usage{}, fmt.Errorf("providers/anthropic: request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkAnthropicResponse(resp); err != nil {
+		return Usage{}, err
+	}
+
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+
+	var (
+		usage      Usage
+		stopReason string
+	)
+
+	if opts.Stream {
+		err := readAnthropicSSEStream(resp.Body, onDelta, &usage, &stopReason)
+		if err == nil {
+			err = checkAnthropicStop(stopReason)
+		}
+		return usage.withEstimatedImageTokens("anthropic", opts.allImages()), err
+	}
+
+	var out anthropicMessageResponse
+
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return Usage{}, fmt.Errorf("providers/anthropic: decode response: %w", err)
+	}
+
+	out.Usage.applyTo(&usage)
+
+	for _, block := range out.Content {
+		if err := emitAnthropicBlock(block.Type, block.Text, block.Thinking, onDelta); err != nil {
+			return usage, err
+		}
+	}
+	return usage.withEstimatedImageTokens("anthropic", opts.allImages()), checkAnthropicStop(out.StopReason)
+}
+
+// AnthropicStopError reports a response the model did not finish: "max_tokens" when the
+// output hit the token cap, or "refusal" when the model declined to answer.
+type AnthropicStopError struct {
+	Reason string // stop_reason
+}
+
+func (e *AnthropicStopError) Error() string {
+	return "providers/anthropic: response stopped: " + e.Reason
+}
+
+// checkAnthropicStop turns a stop_reason that leaves the answer incomplete into an
+// AnthropicStopError.
+func checkAnthropicStop(reason string) error {
+	switch reason {
+	case "max_tokens", "refusal":
+		return &AnthropicStopError{Reason: reason}
+	}
+	return nil
+}
+
+func buildAnthropicRequestBody(opts ChatOptions) ([]byte, error) {
+	turns := opts.turns()
+	messages := make([]anthropicMessage, 0, len(turns))
+
+	for _, t := range turns {
+		if content := anthropicContent(t); len(content) > 0 {
+			messages = append(messages, anthropicMessage{Role: t.Role, Content: content})
+		}
+	}
+
+	if len(messages) == 0 {
+		return nil, fmt.Errorf("providers/anthropic: prompt or images are required")
+	}
+
+	req := anthropicMessageRequest{
+		Model:     opts.Model,
+		MaxTokens: anthropicDefaultMaxTokens,
+		System:    strings.TrimSpace(opts.SystemPrompt),
+		Messages:  messages,
+		Stream:    opts.Stream,
+
+		StopSequences: opts.stop(),
+	}
+
+	// Anthropic has no seed parameter.
+	if opts.MaxTokens > 0 {
+		req.MaxTokens = opts.MaxTokens
+	}
+
+	if v, ok := opts.Options["max_tokens"]; ok {
+		if i := toInt(v); i != nil && *i > 0 {
+			req.MaxTokens = *i
+		}
+	}
+
+	applyAnthropicSampling(&req, opts)
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	enc.SetEscapeHTML(false)
+
+	if err := enc.Encode(req); err != nil {
+		return nil, fmt.Errorf("providers/anthropic: encode request: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// anthropicContent converts one turn into image blocks followed by the text block.
+func anthropicContent(t Message) []anthropicContentBlock {
+	content := make([]anthropicContentBlock, 0, 1+len(t.Images))
+
+	for _, img := range t.Images {
+		if len(img) == 0 {
+			continue
+		}
+
+		mime := http.DetectContentType(img)
+		if !strings.HasPrefix(mime, "image/") {
+			mime = "image/png"
+		}
+
+		content = append(content, anthropicContentBlock{
+			Type: "image",
+			Source: &anthropicImageSource{
+				Type:      "base64",
+				MediaType: mime,
+				Data:      base64.StdEncoding.EncodeToString(img),
+			},
+		})
+	}
+
+	if text := strings.TrimSpace(t.Content); text != "" {
+		content = append(content, anthropicContentBlock{Type: "text", Text: text})
+	}
+	return content
+}
+
+// applyAnthropicSampling sets thinking or sampling controls. Extended thinking rejects custom
+// temperature/top_p, and recent models reject temperature and top_p together, so top_p is only
+// sent when no temperature is configured.
+func applyAnthropicSampling(req *anthropicMessageRequest, opts ChatOptions) {
+	if budget := opts.thinkingBudget(); budget > 0 {
+		budget = max(budget, anthropicMinThinkingBudget)
+		if req.MaxTokens <= budget {
+			req.MaxTokens = budget + anthropicDefaultMaxTokens
+		}
+
+		req.Thinking = &anthropicThinking{Type: "enabled", BudgetTokens: budget}
+		return
+	}
+
+	if opts.Temperature != 0 {
+		req.Temperature = floatPtr(opts.Temperature)
+		return
+	}
+
+	if opts.TopP != 0 {
+		req.TopP = floatPtr(opts.TopP)
+	}
+}
+
+func (a *Anthropic) newRequest(
+	ctx context.Context, method, endpoint string, body []byte, stream bool,
+) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("providers/anthropic: build request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", a.userAgent)
+	req.Header.Set("Anthropic-Version", anthropicVersion)
+
+	if stream {
+		req.Header.Set("Accept", "text/event-stream")
+	} else {
+		req.Header.Set("Accept", "application/json")
+	}
+
+	if a.authType == "auth_token" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	} else {
+		req.Header.Set("X-Api-Key", a.apiKey)
+	}
+	return req, nil
+}
+
+func checkAnthropicResponse(resp *http.Response) error {
+	if resp.StatusCode < httpStatusClientErr {
+		return nil
+	}
+
+	body, _ := io.ReadAll(resp.Body)
+
+	var apiErr anthropicErrorEnvelope
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Error == nil {
+		apiErr.Error = &anthropicErrorPayload{Type: "http_error", Message: strings.TrimSpace(string(body))}
+	}
+
+	apiErr.Error.StatusCode = resp.StatusCode
+	apiErr.Error.RetryAfter = parseRetryAfter(resp.Header)
+	return apiErr.Error
+}
+
+// readAnthropicSSEStream consumes Messages API server-sent events until message_stop and
+// records the stop_reason.
+func readAnthropicSSEStream(
+	body io.Reader, onDelta func(string, string) error, usage *Usage, stopReason *string,
+) error {
+	reader := bufio.NewReader(body)
+
+	var dataBuf bytes.Buffer
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("providers/anthropic: read stream: %w", err)
+		}
+
+		line = strings.TrimRight(line, "\r\n")
+
+		if strings.HasPrefix(line, "data:") {
+			if dataBuf.Len() > 0 {
+				dataBuf.WriteByte('\n')
+			}
+
+			dataBuf.WriteString(strings.TrimSpace(strings.TrimPrefix(line, "data:")))
+		} else if line == "" && dataBuf.Len() > 0 {
+			done, derr := dispatchAnthropicEvent(dataBuf.Bytes(), onDelta, usage, stopReason)
+			if done || derr != nil {
+				return derr
+			}
+
+			dataBuf.Reset()
+		}
+
+		if errors.Is(err, io.EOF) {
+			if dataBuf.Len() > 0 {
+				if done, derr := dispatchAnthropicEvent(dataBuf.Bytes(), onDelta, usage, stopReason); done || derr != nil {
+					return derr
+				}
+			}
+			// The API always terminates with message_stop; anything else is a cut connection.
+			return fmt.Errorf("providers/anthropic: stream ended before message_stop: %w", io.ErrUnexpectedEOF)
+		}
+	}
+}
+
+// dispatchAnthropicEvent handles a single SSE data payload and reports whether the stream has ended.
+func dispatchAnthropicEvent(
+	payload []byte, onDelta func(string, string) error, usage *Usage, stopReason *string,
+) (bool, error) {
+	var ev anthropicStreamEvent
+	if err := json.Unmarshal(payload, &ev); err != nil {
+		return true, fmt.Errorf("providers/anthropic: decode stream event: %w", err)
+	}
+
+	switch ev.Type {
+	case "message_start":
+		if ev.Message != nil {
+			ev.Message.Usage.applyTo(usage)
+		}
+	case "message_delta":
+		if ev.Usage != nil {
+			ev.Usage.applyTo(usage)
+		}
+
+		if ev.Delta != nil && ev.Delta.StopReason != "" {
+			*stopReason = ev.Delta.StopReason
+		}
+	case "content_block_delta":
+		if ev.Delta == nil {
+			return false, nil
+		}
+
+		switch ev.Delta.Type {
+		case "text_delta":
+			return false, emitAnthropicBlock("text", ev.Delta.Text, "", onDelta)
+		case "thinking_delta":
+			return false, emitAnthropicBlock("thinking", "", ev.Delta.Thinking, onDelta)
+		}
+	case "message_stop":
+		return true, nil
+	case "error":
+		if ev.Error != nil {
+			// Errors after the 200 header carry no status, so derive one for retries and fallbacks.
+			ev.Error.StatusCode = anthropicErrorStatus[ev.Error.Type]
+			return true, ev.Error
+		}
+		return true, fmt.Errorf("providers/anthropic: stream error")
+	}
+	return false, nil
+}
+
+func emitAnthropicBlock(blockType, text, thinking string, onDelta func(string, string) error) error {
+	switch blockType {
+	case "text":
+		if text == "" {
+			return nil
+		}
+		return onDelta(text, "")
+	case "thinking":
+		if thinking == "" {
+			return nil
+		}
+		return onDelta("", thinking)
+	}
+	return nil
+}
+
+type anthropicMessageRequest struct {
+	Model       string             `json:"model"`
+	MaxTokens   int                `json:"max_tokens"`
+	System      string             `json:"system,omitempty"`
+	Messages    []anthropicMessage `json:"messages"`
+	Temperature *float64           `json:"temperature,omitempty"`
+	TopP        *float64           `json:"top_p,omitempty"`
+	Thinking    *anthropicThinking `json:"thinking,omitempty"`
+	Stream      bool               `json:"stream,omitempty"`
+
+	StopSequences []string `json:"stop_sequences,omitempty"`
+}
+
+type anthropicMessage struct {
+	Role    string                  `json:"role"`
+	Content []anthropicContentBlock `json:"content"`
+}
+
+type anthropicContentBlock struct {
+	Type   string                `json:"type"`
+	Text   string                `json:"text,omitempty"`
+	Source *anthropicImageSource `json:"source,omitempty"`
+}
+
+type anthropicImageSource struct {
+	Type      string `json:"type"`
+	MediaType string `json:"media_type"`
+	Data      string `json:"data"`
+}
+
+type anthropicThinking struct {
+	Type         string `json:"type"`
+	BudgetTokens int    `json:"budget_tokens"`
+}
+
+type anthropicMessageResponse struct {
+	Content    []anthropicResponseBlock `json:"content"`
+	StopReason string                   `json:"stop_reason"`
+	Usage      anthropicUsage           `json:"usage"`
+}
+
+type anthropicUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+// applyTo copies non-zero counts; streams report input on message_start and output on message_delta.
+func (au anthropicUsage) applyTo(u *Usage) {
+	if au.InputTokens > 0 {
+		u.PromptTokens = au.InputTokens
+	}
+
+	if au.OutputTokens > 0 {
+		u.CompletionTokens = au.OutputTokens
+	}
+}
+
+type anthropicResponseBlock struct {
+	Type     string `json:"type"`
+	Text     string `json:"text"`
+	Thinking string `json:"thinking"`
+}
+
+type anthropicStreamEvent struct {
+	Type    string                    `json:"type"`
+	Message *anthropicMessageResponse `json:"message"`
+	Delta   *anthropicStreamDelta     `json:"delta"`
+	Usage   *anthropicUsage           `json:"usage"`
+	Error   *anthropicErrorPayload    `json:"error"`
+}
+
+type anthropicStreamDelta struct {
+	Type       string `json:"type"`
+	Text       string `json:"text"`
+	Thinking   string `json:"thinking"`
+	StopReason string `json:"stop_reason"` // on message_delta
+}
+
+// anthropicErrorStatus maps the error types sent as stream events to the HTTP status the API
+// uses for them.
+var anthropicErrorStatus = map[string]int{
+	"invalid_request_error": http.StatusBadRequest,
+	"authentication_error":  http.StatusUnauthorized,
+	"permission_error":      http.StatusForbidden,
+	"not_found_error":       http.StatusNotFound,
+	"request_too_large":     http.StatusRequestEntityTooLarge,
+	"rate_limit_error":      http.StatusTooManyRequests,
+	"api_error":             http.StatusInternalServerError,
+	"overloaded_error":      anthropicStatusOverloaded,
+}
+
+type anthropicErrorEnvelope struct {
+	Error *anthropicErrorPayload `json:"error"`
+}
+
+type anthropicErrorPayload struct {
+	StatusCode int           `json:"-"`
+	RetryAfter time.Duration `json:"-"`
+	Type       string        `json:"type"`
+	Message    string        `json:"message"`
+}
+
+func (e *anthropicErrorPayload) Error() string {
+	if e == nil {
+		return ""
+	}
+
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("providers/anthropic: %s (%d): %s", e.Type, e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("providers/anthropic: %s: %s", e.Type, e.Message)
+}
+
+// anthropicModelList is one page of GET /v1/models.
+type anthropicModelList struct {
+	Data []struct {
+		ID          string `json:"id"`
+		DisplayName string `json:"display_name"`
+	} `json:"data"`
+	HasMore bool   `json:"has_more"`
+	LastID  string `json:"last_id"`
+}
+
+// ListModels implements ModelLister using /v1/models. The API does not report modalities.
+func (a *Anthropic) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	base := strings.TrimSuffix(a.endpoint, "/messages") + "/models?limit=1000"
+
+	var models []ModelInfo
+	for after := ""; ; {
+		endpoint := base
+		if after != "" {
+			endpoint += "&after_id=" + url.QueryEscape(after)
+		}
+
+		page, err := a.listModelsPage(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range page.Data {
+			models = append(models, ModelInfo{ID: m.ID, DisplayName: m.DisplayName})
+		}
+
+		if !page.HasMore || page.LastID == "" {
+			return models, nil
+		}
+		after = page.LastID
+	}
+}
+
+func (a *Anthropic) listModelsPage(ctx context.Context, endpoint string) (*anthropicModelList, error) {
+	req, err := a.newRequest(ctx, http.MethodGet, endpoint, nil, false)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("providers/anthropic: list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkAnthropicResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var page anthropicModelList
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("providers/anthropic: decode models: %w", err)
+	}
+	return &page, nil
+}
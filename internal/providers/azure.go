@@ -0,0 +1,81 @@
+package providers
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/option"
+)
+
+const (
+	// defaultAzureAPIVersion is the latest GA data-plane version supporting vision and json_schema.
+	defaultAzureAPIVersion = "2024-10-21"
+	// defaultAzureResponsesAPIVersion is the first version serving /openai/responses; no GA
+	// version does yet.
+	defaultAzureResponsesAPIVersion = "2025-04-01-preview"
+)
+
+// NewAzureOpenAI constructs an OpenAI provider that talks to an Azure OpenAI resource.
+// Requests go to {endpoint}/openai/deployments/{deployment}/chat/completions?api-version=...
+// and authenticate with the api-key header, or with a bearer Entra ID token when AuthType
+// is "auth_token". With the Responses API the deployment is sent as the model instead, and
+// the default api-version is a preview one, as GA versions don't serve /responses.
+func NewAzureOpenAI(cfg ProviderConfig) (*OpenAI, error) {
+	endpoint := strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/")
+	if endpoint == "" {
+		return nil, fmt.Errorf("providers/azure: baseURL (resource endpoint) is required")
+	}
+
+	endpoint = strings.TrimSuffix(endpoint, "/openai")
+
+	deployment := strings.TrimSpace(cfg.Azure.Deployment)
+	if deployment == "" {
+		return nil, fmt.Errorf("providers/azure: deployment is required")
+	}
+
+	api, ok := openAIAPIMode(cfg.OpenAI.API)
+	if !ok {
+		return nil, fmt.Errorf("providers/azure: unsupported api %q (want chat or responses)", cfg.OpenAI.API)
+	}
+
+	apiVersion := strings.TrimSpace(cfg.Azure.APIVersion)
+	if apiVersion == "" {
+		apiVersion = defaultAzureAPIVersion
+		if api == openAIAPIResponses {
+			apiVersion = defaultAzureResponsesAPIVersion
+		}
+	}
+
+	base := endpoint + "/openai/deployments/" + url.PathEscape(deployment) + "/"
+	if api == openAIAPIResponses {
+		base = endpoint + "/openai/"
+	}
+
+	httpClient, err := cfg.HTTP.client(0)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := []option.RequestOption{
+		option.WithHTTPClient(httpClient),
+		option.WithBaseURL(base),
+		option.WithQuery("api-version", apiVersion),
+		// Drop any bearer picked up from OPENAI_API_KEY; Azure keys go in api-key.
+		option.WithHeaderDel("Authorization"),
+		option.WithHeader("User-Agent", cfg.HTTP.userAgent()),
+		option.WithMaxRetries(0),
+	}
+
+	if key := strings.TrimSpace(cfg.APIKey); key != "" {
+		if strings.EqualFold(strings.TrimSpace(cfg.AuthType), "auth_token") {
+			opts = append(opts, option.WithHeader("Authorization", "Bearer "+key))
+		} else {
+			opts = append(opts, option.WithHeader("Api-Key", key))
+		}
+	}
+
+	c := openai.NewClient(opts...)
+	return &OpenAI{client: c, api: api, deployment: deployment}, nil
+}
@@ -0,0 +1,295 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/utils"
+)
+
+// cacheKeyVersion is mixed into every key; bump it when the key or entry layout changes.
+const cacheKeyVersion = "v1"
+
+// ResponseCache stores final model outputs on disk, addressed by a hash of the request.
+type ResponseCache struct {
+	dir string
+}
+
+// cacheEntry is one cached response.
+type cacheEntry struct {
+	Provider  string    `json:"provider"`
+	Model     string    `json:"model"`
+	Content   string    `json:"content"`
+	Thinking  string    `json:"thinking,omitempty"`
+	Usage     Usage     `json:"usage"`
+	CreatedAt time.Time `json:"createdAt"`
+}
+
+// DefaultCacheDir returns the per-user response cache location, e.g. ~/.cache/dino/responses.
+func DefaultCacheDir() (string, error) {
+	base, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("providers/cache: %w", err)
+	}
+	return filepath.Join(base, "dino", "responses"), nil
+}
+
+// NewResponseCache opens (and creates) a cache rooted at dir.
+func NewResponseCache(dir string) (*ResponseCache, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("providers/cache: create %s: %w", dir, err)
+	}
+	return &ResponseCache{dir: dir}, nil
+}
+
+// Dir returns the cache root.
+func (c *ResponseCache) Dir() string { return c.dir }
+
+// Prune removes entries not used within olderThan; zero removes everything. It returns the
+// number of entries removed and the bytes freed.
+func (c *ResponseCache) Prune(olderThan time.Duration) (int, int64, error) {
+	cutoff := time.Now().Add(-olderThan)
+
+	var (
+		removed int
+		freed   int64
+	)
+
+	err := filepath.WalkDir(c.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		if d.IsDir() || filepath.Ext(path) != ".json" {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+
+		if olderThan > 0 && info.ModTime().After(cutoff) {
+			return nil
+		}
+
+		if err := os.Remove(path); err != nil {
+			return err
+		}
+
+		removed++
+		freed += info.Size()
+
+		return nil
+	})
+	if err != nil {
+		return removed, freed, fmt.Errorf("providers/cache: prune: %w", err)
+	}
+	return removed, freed, nil
+}
+
+// path shards entries by the first two hex digits so large runs don't crowd one directory.
+func (c *ResponseCache) path(key string) string {
+	return filepath.Join(c.dir, key[:2], key+".json")
+}
+
+func (c *ResponseCache) get(key string) (cacheEntry, bool) {
+	path := c.path(key)
+
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return cacheEntry{}, false
+	}
+
+	var entry cacheEntry
+	if json.Unmarshal(raw, &entry) != nil {
+		return cacheEntry{}, false
+	}
+
+	// Touch the entry so prune evicts the least recently used ones first.
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+
+	return entry, true
+}
+
+func (c *ResponseCache) put(key string, entry cacheEntry) error {
+	path := c.path(key)
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		return fmt.Errorf("providers/cache: %w", err)
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("providers/cache: encode: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("providers/cache: %w", err)
+	}
+	return nil
+}
+
+// cachingProvider answers repeated requests from a ResponseCache.
+type cachingProvider struct {
+	next  Provider
+	name  string
+	scope []string
+	cache *ResponseCache
+}
+
+// WithCache wraps p so identical requests to the named provider, configured by cfg, are
+// served from c without a network call. Hits replay the stored thinking and content through
+// OnDelta and report Usage.Cached with no tokens. Only successful responses that pass
+// ChatOptions.Cacheable are stored.
+func WithCache(p Provider, name string, cfg ProviderConfig, c *ResponseCache) Provider {
+	if p == nil || c == nil {
+		return p
+	}
+
+	name = strings.ToLower(strings.TrimSpace(name))
+
+	return &cachingProvider{next: p, name: name, scope: cacheScope(name, cfg), cache: c}
+}
+
+// cacheScope lists the endpoint settings that change what a model name refers to or how it
+// answers, so two gateways serving the same model name don't share entries.
+func cacheScope(name string, cfg ProviderConfig) []string {
+	var scope []string
+
+	add := func(k, v string) {
+		scope = append(scope, k+"="+v)
+	}
+	addJSON := func(k string, v any) {
+		raw, _ := json.Marshal(v)
+		add(k, string(raw))
+	}
+
+	add("base_url", strings.TrimRight(strings.TrimSpace(cfg.BaseURL), "/"))
+
+	switch name {
+	case "openai":
+		add("api", cfg.OpenAI.API)
+	case "azure":
+		add("api", cfg.OpenAI.API)
+		add("deployment", cfg.Azure.Deployment)
+		add("api_version", cfg.Azure.APIVersion)
+	case "ollama":
+		addJSON("ollama", cfg.Ollama.options())
+	case "gemini":
+		addJSON("safety_settings", cfg.Gemini.SafetySettings)
+	case "exec":
+		addJSON("command", cfg.Exec.Command)
+		add("dir", cfg.Exec.Dir)
+	}
+	return scope
+}
+
+// Chat implements Provider.
+func (cp *cachingProvider) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	key := cacheKey(cp.name, cp.scope, opts)
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+
+	if entry, ok := cp.cache.get(key); ok {
+		if entry.Thinking != "" {
+			if err := onDelta("", entry.Thinking); err != nil {
+				return Usage{Cached: true}, err
+			}
+		}
+		return Usage{Cached: true}, onDelta(entry.Content, "")
+	}
+
+	var content, thinking strings.Builder
+
+	callOpts := opts
+	callOpts.OnDelta = func(c, t string) error {
+		content.WriteString(c)
+		thinking.WriteString(t)
+
+		return onDelta(c, t)
+	}
+	callOpts.OnRetry = func(attempt int, err error, wait time.Duration) {
+		content.Reset()
+		thinking.Reset()
+
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err, wait)
+		}
+	}
+
+	usage, err := cp.next.Chat(ctx, callOpts)
+	if err != nil || content.Len() == 0 {
+		return usage, err
+	}
+
+	// An answer the caller can't use would otherwise be replayed on every later run.
+	if opts.Cacheable != nil && !opts.Cacheable(content.String()) {
+		return usage, nil
+	}
+
+	entry := cacheEntry{
+		Provider:  cp.name,
+		Model:     opts.Model,
+		Content:   content.String(),
+		Thinking:  thinking.String(),
+		Usage:     usage,
+		CreatedAt: time.Now().UTC(),
+	}
+	// A failed write only costs a future cache miss, so it does not fail the call.
+	_ = cp.cache.put(key, entry)
+
+	return usage, nil
+}
+
+// cacheKey extends the request hash with the provider, its endpoint scope and every setting
+// that changes output.
+func cacheKey(provider string, scope []string, opts ChatOptions) string {
+	h := sha256.New()
+
+	writeField(h, []byte(cacheKeyVersion))
+	writeField(h, []byte(provider))
+
+	for _, s := range scope {
+		writeField(h, []byte(s))
+	}
+	writeRequest(h, opts)
+	writeField(h, []byte(strconv.FormatUint(math.Float64bits(opts.Temperature), 16)))
+	writeField(h, []byte(strconv.FormatUint(math.Float64bits(opts.TopP), 16)))
+	writeField(h, []byte(opts.reasoningEffort()))
+	writeField(h, []byte(strconv.Itoa(opts.thinkingBudget())))
+
+	writeField(h, []byte("max_tokens="+strconv.Itoa(opts.MaxTokens)))
+
+	seed := "none"
+	if opts.Seed != nil {
+		seed = strconv.FormatInt(*opts.Seed, 10)
+	}
+	writeField(h, []byte("seed="+seed))
+
+	stop := opts.stop()
+	writeField(h, []byte("stop="+strconv.Itoa(len(stop))))
+
+	for _, s := range stop {
+		writeField(h, []byte(s))
+	}
+	writeField(h, []byte("detail="+opts.imageDetail()))
+
+	// encoding/json sorts map keys, so equal maps hash equally.
+	options := []byte("{}")
+	if len(opts.Options) > 0 {
+		options, _ = json.Marshal(opts.Options)
+	}
+	writeField(h, options)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
@@ -0,0 +1,179 @@
+package providers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/utils"
+)
+
+// ErrCassetteMiss is returned in replay mode when no recording matches a request.
+var ErrCassetteMiss = errors.New("providers/cassette: no recording for request")
+
+// cassetteEntry is one recorded Chat call as stored on disk.
+type cassetteEntry struct {
+	Model      string          `json:"model"`
+	RecordedAt time.Time       `json:"recordedAt"`
+	Chunks     []cassetteChunk `json:"chunks"`
+	Usage      Usage           `json:"usage"`
+}
+
+// cassetteChunk is one OnDelta call, replayed in order.
+type cassetteChunk struct {
+	Content  string `json:"content,omitempty"`
+	Thinking string `json:"thinking,omitempty"`
+}
+
+// recordingProvider stores every successful Chat call of next under dir.
+type recordingProvider struct {
+	next Provider
+	dir  string
+}
+
+// WithRecorder wraps p so each successful response is saved under dir, keyed by a hash of the
+// model, prompts, messages, schema and image bytes. Existing recordings are overwritten.
+func WithRecorder(p Provider, dir string) (Provider, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("providers/cassette: create %s: %w", dir, err)
+	}
+	return &recordingProvider{next: p, dir: dir}, nil
+}
+
+// Chat implements Provider.
+func (r *recordingProvider) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+	entry := cassetteEntry{Model: opts.Model}
+
+	recOpts := opts
+	recOpts.OnDelta = func(content, thinking string) error {
+		entry.Chunks = append(entry.Chunks, cassetteChunk{Content: content, Thinking: thinking})
+		return onDelta(content, thinking)
+	}
+	// A retried attempt starts over, so drop what the failed one streamed.
+	recOpts.OnRetry = func(attempt int, err error, wait time.Duration) {
+		entry.Chunks = entry.Chunks[:0]
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err, wait)
+		}
+	}
+
+	usage, err := r.next.Chat(ctx, recOpts)
+	if err != nil {
+		return usage, err
+	}
+
+	entry.Usage = usage
+	entry.RecordedAt = time.Now().UTC()
+
+	if err := writeCassette(filepath.Join(r.dir, cassetteKey(opts)+".json"), entry); err != nil {
+		return usage, err
+	}
+	return usage, nil
+}
+
+// replayProvider serves Chat calls from recordings without touching the network.
+type replayProvider struct {
+	dir string
+}
+
+// NewReplayer returns a Provider that answers from recordings made by WithRecorder. Requests
+// without a recording fail with ErrCassetteMiss. Replayed calls report no usage since nothing
+// was billed.
+func NewReplayer(dir string) (Provider, error) {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return nil, fmt.Errorf("providers/cassette: %w", err)
+	}
+
+	if !info.IsDir() {
+		return nil, fmt.Errorf("providers/cassette: %s is not a directory", dir)
+	}
+	return &replayProvider{dir: dir}, nil
+}
+
+// Chat implements Provider.
+func (r *replayProvider) Chat(_ context.Context, opts ChatOptions) (Usage, error) {
+	key := cassetteKey(opts)
+
+	raw, err := os.ReadFile(filepath.Join(r.dir, key+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Usage{}, fmt.Errorf("%w (model %s, key %s)", ErrCassetteMiss, opts.Model, key)
+	}
+
+	if err != nil {
+		return Usage{}, fmt.Errorf("providers/cassette: read %s: %w", key, err)
+	}
+
+	var entry cassetteEntry
+	if err := json.Unmarshal(raw, &entry); err != nil {
+		return Usage{}, fmt.Errorf("providers/cassette: decode %s: %w", key, err)
+	}
+
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+	for _, ch := range entry.Chunks {
+		if err := onDelta(ch.Content, ch.Thinking); err != nil {
+			return Usage{}, err
+		}
+	}
+	return Usage{Cached: true}, nil
+}
+
+// cassetteKey hashes everything that determines the model input: model, prompts, history,
+// schema and image bytes. Sampling settings are deliberately left out.
+func cassetteKey(opts ChatOptions) string {
+	h := sha256.New()
+	writeRequest(h, opts)
+
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// writeRequest hashes the model input: model, prompts, history, image bytes and schema.
+func writeRequest(h hash.Hash, opts ChatOptions) {
+	writeField(h, []byte(opts.Model))
+	writeField(h, []byte(opts.SystemPrompt))
+
+	for _, t := range opts.turns() {
+		writeField(h, []byte(t.Role))
+		writeField(h, []byte(t.Content))
+
+		for _, img := range t.Images {
+			sum := sha256.Sum256(img)
+			writeField(h, sum[:])
+		}
+	}
+
+	if !opts.NoResponseFormat {
+		writeField(h, opts.Format)
+	}
+}
+
+// writeField writes a length-prefixed value so adjacent fields cannot collide.
+func writeField(h hash.Hash, b []byte) {
+	var n [8]byte
+
+	binary.BigEndian.PutUint64(n[:], uint64(len(b)))
+	h.Write(n[:])
+	h.Write(b)
+}
+
+// writeCassette writes entry to path atomically so readers never see partial JSON.
+func writeCassette(path string, entry cassetteEntry) error {
+	data, err := json.MarshalIndent(entry, "", "  ")
+	if err != nil {
+		return fmt.Errorf("providers/cassette: encode: %w", err)
+	}
+
+	if err := utils.WriteFileAtomic(path, data, 0o644); err != nil {
+		return fmt.Errorf("providers/cassette: %w", err)
+	}
+	return nil
+}
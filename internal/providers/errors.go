@@ -0,0 +1,156 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Error kinds shared by all providers. Classify wraps provider errors so that
+// errors.Is(err, ErrRateLimited) and friends work regardless of the provider.
+var (
+	ErrRateLimited     = errors.New("rate limited")
+	ErrAuth            = errors.New("authentication failed")
+	ErrModelNotFound   = errors.New("model not found")
+	ErrContextTooLong  = errors.New("context too long")
+	ErrContentFiltered = errors.New("content filtered")
+	ErrTransient       = errors.New("transient failure")
+)
+
+// Error is a classified provider failure. It unwraps to both its Kind and the original error,
+// so provider-specific types stay reachable through errors.As.
+type Error struct {
+	Kind       error         // one of the Err* kinds above
+	Provider   string        // e.g. "gemini"
+	StatusCode int           // HTTP status, 0 when there was none
+	RetryAfter time.Duration // server retry hint, 0 when there was none
+	Err        error
+}
+
+func (e *Error) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *Error) Unwrap() []error { return []error{e.Kind, e.Err} }
+
+// Retryable reports whether the same request may succeed later.
+func (e *Error) Retryable() bool {
+	return e.Kind == ErrRateLimited || e.Kind == ErrTransient
+}
+
+// Classify wraps err in an *Error when its kind can be determined from the status code,
+// the provider's error payload or the transport failure. Other errors, including context
+// cancellation and already classified errors, are returned unchanged.
+func Classify(provider string, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var classified *Error
+	if errors.As(err, &classified) {
+		return err
+	}
+
+	kind := errorKind(err)
+	if kind == nil {
+		return err
+	}
+
+	return &Error{
+		Kind:       kind,
+		Provider:   provider,
+		StatusCode: StatusCode(err),
+		RetryAfter: RetryAfter(err),
+		Err:        err,
+	}
+}
+
+// Message fragments that identify a kind when the status code alone is ambiguous, typically
+// 400 Bad Request. Matched against the lowercased error text.
+var (
+	contextTooLongHints = []string{
+		"context_length_exceeded", "context length", "maximum context", "prompt is too long",
+		"too many tokens", "exceeds the maximum number of tokens", "input token count",
+	}
+	contentFilterHints = []string{"content_filter", "content_policy", "content management policy", "responsibleai"}
+	authHints          = []string{
+		"api key not valid", "invalid api key", "invalid_api_key", "api_key_invalid", "invalid x-api-key",
+	}
+	modelHints = []string{"model_not_found", "does not exist", "is not found", "not supported for generatecontent"}
+	// A 404 only rules out the route when it is about the model or the Azure deployment.
+	notFoundModelHints = []string{"model", "deployment"}
+)
+
+// errorKind determines the kind of err, or nil when it is unknown.
+func errorKind(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return nil
+	}
+
+	var blocked *GeminiBlockedError
+	if errors.As(err, &blocked) {
+		// A MAX_TOKENS stop is a truncated answer, not a filtered one.
+		if blocked.Reason == "MAX_TOKENS" {
+			return nil
+		}
+		return ErrContentFiltered
+	}
+
+	var stopped *AnthropicStopError
+	if errors.As(err, &stopped) {
+		if stopped.Reason == "refusal" {
+			return ErrContentFiltered
+		}
+		return nil
+	}
+
+	switch status := StatusCode(err); {
+	case status == http.StatusUnauthorized || status == http.StatusForbidden:
+		return ErrAuth
+	case status == http.StatusNotFound:
+		// Other lookups 404 too, such as an expired Gemini file; those only fail the one image.
+		if containsAny(strings.ToLower(err.Error()), notFoundModelHints) {
+			return ErrModelNotFound
+		}
+		return nil
+	case status == http.StatusRequestEntityTooLarge:
+		return ErrContextTooLong
+	case status == http.StatusTooManyRequests:
+		return ErrRateLimited
+	case status == http.StatusRequestTimeout || status == http.StatusTooEarly || status >= http.StatusInternalServerError:
+		return ErrTransient
+	case status != 0:
+		return kindFromMessage(strings.ToLower(err.Error()))
+	}
+
+	if isTransportFailure(err) {
+		return ErrTransient
+	}
+	return nil
+}
+
+// kindFromMessage classifies client errors that only the message tells apart.
+func kindFromMessage(msg string) error {
+	switch {
+	case containsAny(msg, contextTooLongHints):
+		return ErrContextTooLong
+	case containsAny(msg, contentFilterHints):
+		return ErrContentFiltered
+	case containsAny(msg, authHints):
+		return ErrAuth
+	case strings.Contains(msg, "model") && containsAny(msg, modelHints):
+		return ErrModelNotFound
+	}
+	return nil
+}
+
+func containsAny(s string, subs []string) bool {
+	for _, sub := range subs {
+		if strings.Contains(s, sub) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,414 @@
+package providers
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// ExecConfig controls the exec provider.
+type ExecConfig struct {
+	Command []string // program and arguments, e.g. ["python3", "detector.py"]
+	Dir     string   // working directory; empty uses the current one
+	Env     []string // KEY=VALUE entries added to the inherited environment
+}
+
+// Exec is a Provider backed by an external process that speaks newline-delimited JSON.
+//
+// The process is started on first use and kept running. For every Chat call one execRequest
+// line is written to its stdin; the process answers on stdout with any number of lines
+//
+//	{"id":1,"content":"[{\"label\":","thinking":"..."}
+//
+// followed by {"id":1,"done":true,"usage":{...}}, or {"id":1,"error":"...","status":503} to
+// fail the call (a status makes retries and fallbacks apply). Logs belong on stderr, which
+// is passed through. The API key and base URL are exported as DINO_EXEC_API_KEY and
+// DINO_EXEC_BASE_URL.
+//
+// Responses are routed by id, so with --concurrency several requests are in flight at once
+// and the process may answer them in any order; one that reads a line at a time simply
+// handles them in turn. Lines without an id belong to the only pending request.
+type Exec struct {
+	cfg ExecConfig
+	env []string
+
+	mu     sync.Mutex
+	proc   *execProcess
+	nextID uint64
+}
+
+// execRequest is the line sent to the process for one Chat call.
+type execRequest struct {
+	ID           uint64          `json:"id"`
+	Model        string          `json:"model"`
+	SystemPrompt string          `json:"systemPrompt,omitempty"`
+	Messages     []execMessage   `json:"messages,omitempty"` // earlier turns
+	Prompt       string          `json:"prompt"`
+	Images       []string        `json:"images,omitempty"` // base64
+	ImageNames   []string        `json:"imageNames,omitempty"`
+	Format       json.RawMessage `json:"format,omitempty"` // JSON schema or "json"; omitted with noResponseFormat
+	Stream       bool            `json:"stream"`
+	Options      execOptions     `json:"options"`
+}
+
+type execMessage struct {
+	Role    string   `json:"role"`
+	Content string   `json:"content"`
+	Images  []string `json:"images,omitempty"`
+}
+
+type execOptions struct {
+	Temperature     float64        `json:"temperature,omitempty"`
+	TopP            float64        `json:"topP,omitempty"`
+	Think           bool           `json:"think,omitempty"`
+	ReasoningEffort string         `json:"reasoningEffort,omitempty"`
+	ThinkingBudget  int            `json:"thinkingBudget,omitempty"`
+	MaxTokens       int            `json:"maxTokens,omitempty"`
+	Seed            *int64         `json:"seed,omitempty"`
+	Stop            []string       `json:"stop,omitempty"`
+	Extra           map[string]any `json:"extra,omitempty"`
+}
+
+// execResponse is one line read from the process.
+type execResponse struct {
+	ID       uint64 `json:"id"`
+	Content  string `json:"content"`
+	Thinking string `json:"thinking"`
+	Done     bool   `json:"done"`
+	Usage    *Usage `json:"usage"`
+	Error    string `json:"error"`
+	Status   int    `json:"status"`
+}
+
+// execError is a failure reported by the process itself.
+type execError struct {
+	Message    string
+	StatusCode int
+}
+
+func (e *execError) Error() string {
+	if e.StatusCode != 0 {
+		return fmt.Sprintf("providers/exec: %s (%d)", e.Message, e.StatusCode)
+	}
+	return "providers/exec: " + e.Message
+}
+
+// execProcess is a running plugin with its pipes. A single reader hands response lines to
+// the pending calls.
+type execProcess struct {
+	cmd   *exec.Cmd
+	stdin io.WriteCloser
+	once  sync.Once
+
+	done chan struct{} // closed when stdout can no longer be read
+	err  error         // why; set before done is closed
+
+	writeMu sync.Mutex
+	mu      sync.Mutex
+	pending map[uint64]*execCall
+}
+
+// execCall is one request waiting for its response lines.
+type execCall struct {
+	lines chan execResponse
+	gone  chan struct{} // closed when the caller stops reading
+}
+
+// kill stops the process and reaps it; pending reads fail once the pipes close.
+func (p *execProcess) kill() {
+	p.once.Do(func() {
+		_ = p.stdin.Close()
+		_ = p.cmd.Process.Kill()
+		_ = p.cmd.Wait()
+	})
+}
+
+// dead reports whether the process can no longer answer.
+func (p *execProcess) dead() bool {
+	select {
+	case <-p.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// read relays stdout lines to their calls until the stream ends or turns unreadable; either
+// way the process is taken down and every pending call fails with the reason.
+func (p *execProcess) read(stdout io.Reader) {
+	r := bufio.NewReader(stdout)
+
+	for {
+		raw, err := r.ReadBytes('\n')
+		if err != nil {
+			if errors.Is(err, io.EOF) || errors.Is(err, os.ErrClosed) {
+				err = io.ErrUnexpectedEOF
+			}
+
+			p.fail(fmt.Errorf("providers/exec: process ended before done: %w", err))
+			return
+		}
+
+		if len(strings.TrimSpace(string(raw))) == 0 {
+			continue
+		}
+
+		var msg execResponse
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			p.fail(fmt.Errorf("providers/exec: invalid response line %q: %w", strings.TrimSpace(string(raw)), err))
+			return
+		}
+
+		// Lines for calls that were canceled or never existed are dropped.
+		if call := p.call(msg.ID); call != nil {
+			select {
+			case call.lines <- msg:
+			case <-call.gone:
+			}
+		}
+	}
+}
+
+func (p *execProcess) fail(err error) {
+	p.err = err
+	close(p.done)
+	p.kill()
+}
+
+// call returns the pending call for id, or the only pending one when id is 0.
+func (p *execProcess) call(id uint64) *execCall {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if id != 0 {
+		return p.pending[id]
+	}
+
+	if len(p.pending) == 1 {
+		for _, c := range p.pending {
+			return c
+		}
+	}
+	return nil
+}
+
+func (p *execProcess) register(id uint64) *execCall {
+	c := &execCall{lines: make(chan execResponse), gone: make(chan struct{})}
+
+	p.mu.Lock()
+	p.pending[id] = c
+	p.mu.Unlock()
+
+	return c
+}
+
+func (p *execProcess) unregister(id uint64) {
+	p.mu.Lock()
+	c := p.pending[id]
+	delete(p.pending, id)
+	p.mu.Unlock()
+
+	close(c.gone)
+}
+
+// send writes req as one line; a failed write leaves the stream unusable.
+func (p *execProcess) send(req execRequest) error {
+	line, err := json.Marshal(req)
+	if err != nil {
+		return fmt.Errorf("providers/exec: encode request: %w", err)
+	}
+
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+
+	if _, err := p.stdin.Write(append(line, '\n')); err != nil {
+		p.kill()
+		return fmt.Errorf("providers/exec: write request: %w", err)
+	}
+	return nil
+}
+
+// NewExec constructs an exec provider. The command is not started until the first request.
+func NewExec(cfg ProviderConfig) (*Exec, error) {
+	c := cfg.Exec
+	if len(c.Command) == 0 || strings.TrimSpace(c.Command[0]) == "" {
+		return nil, fmt.Errorf("providers/exec: command is required")
+	}
+
+	env := append([]string(nil), c.Env...)
+	if key := strings.TrimSpace(cfg.APIKey); key != "" {
+		env = append(env, "DINO_EXEC_API_KEY="+key)
+	}
+
+	if base := strings.TrimSpace(cfg.BaseURL); base != "" {
+		env = append(env, "DINO_EXEC_BASE_URL="+base)
+	}
+	return &Exec{cfg: c, env: env}, nil
+}
+
+// Chat implements Provider. Deltas are forwarded as the process writes them, whether or not
+// Stream is set. The returned usage is whatever the process reported.
+func (e *Exec) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	req := newExecRequest(opts)
+
+	proc, err := e.start(&req)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	call := proc.register(req.ID)
+	defer proc.unregister(req.ID)
+
+	if err := proc.send(req); err != nil {
+		return Usage{}, err
+	}
+
+	// The protocol has no cancel message; a canceled call stops waiting and its remaining
+	// lines are dropped.
+	usage, err := awaitExec(ctx, proc, call, onDeltaOrNoop(opts.OnDelta))
+	if err != nil && ctx.Err() != nil {
+		return usage, ctx.Err()
+	}
+	return usage, err
+}
+
+// start returns the running plugin, starting it when needed, and assigns req its id.
+func (e *Exec) start(req *execRequest) (*execProcess, error) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	proc, err := e.process()
+	if err != nil {
+		return nil, err
+	}
+
+	e.nextID++
+	req.ID = e.nextID
+
+	return proc, nil
+}
+
+// process returns the running plugin, starting it when there is none or it has ended.
+func (e *Exec) process() (*execProcess, error) {
+	if e.proc != nil && !e.proc.dead() {
+		return e.proc, nil
+	}
+
+	cmd := exec.Command(e.cfg.Command[0], e.cfg.Command[1:]...)
+	cmd.Dir = e.cfg.Dir
+	cmd.Env = append(os.Environ(), e.env...)
+	cmd.Stderr = os.Stderr
+
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, fmt.Errorf("providers/exec: %w", err)
+	}
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, fmt.Errorf("providers/exec: %w", err)
+	}
+
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("providers/exec: start %s: %w", e.cfg.Command[0], err)
+	}
+
+	e.proc = &execProcess{cmd: cmd, stdin: stdin, done: make(chan struct{}), pending: map[uint64]*execCall{}}
+	go e.proc.read(stdout)
+
+	return e.proc, nil
+}
+
+// awaitExec relays the response lines of call until done or error.
+func awaitExec(
+	ctx context.Context, proc *execProcess, call *execCall, onDelta func(string, string) error,
+) (Usage, error) {
+	var usage Usage
+
+	for {
+		var msg execResponse
+
+		select {
+		case <-ctx.Done():
+			return usage, ctx.Err()
+		case <-proc.done:
+			return usage, proc.err
+		case msg = <-call.lines:
+		}
+
+		if msg.Error != "" {
+			return usage, &execError{Message: msg.Error, StatusCode: msg.Status}
+		}
+
+		if msg.Content != "" || msg.Thinking != "" {
+			if err := onDelta(msg.Content, msg.Thinking); err != nil {
+				return usage, err
+			}
+		}
+
+		if msg.Usage != nil {
+			usage = *msg.Usage
+		}
+
+		if msg.Done {
+			return usage, nil
+		}
+	}
+}
+
+// newExecRequest converts ChatOptions into the wire request, without an ID.
+func newExecRequest(opts ChatOptions) execRequest {
+	req := execRequest{
+		Model:        opts.Model,
+		SystemPrompt: strings.TrimSpace(opts.SystemPrompt),
+		Prompt:       opts.Prompt,
+		Images:       base64Images(opts.Images),
+		ImageNames:   opts.ImageNames,
+		Stream:       opts.Stream,
+		Options: execOptions{
+			Temperature:     opts.Temperature,
+			TopP:            opts.TopP,
+			Think:           opts.thinkingEnabled(),
+			ReasoningEffort: opts.reasoningEffort(),
+			ThinkingBudget:  opts.thinkingBudget(),
+			MaxTokens:       opts.MaxTokens,
+			Seed:            opts.Seed,
+			Stop:            opts.stop(),
+			Extra:           opts.Options,
+		},
+	}
+
+	if !opts.NoResponseFormat {
+		req.Format = ensureFormat(opts.Format)
+	}
+
+	for _, m := range opts.turns() {
+		req.Messages = append(req.Messages, execMessage{Role: m.Role, Content: m.Content, Images: base64Images(m.Images)})
+	}
+
+	// The final turn is already carried by Prompt and Images.
+	if n := len(req.Messages); n > 0 && (strings.TrimSpace(opts.Prompt) != "" || len(opts.Images) > 0) {
+		req.Messages = req.Messages[:n-1]
+	}
+	return req
+}
+
+func base64Images(imgs [][]byte) []string {
+	if len(imgs) == 0 {
+		return nil
+	}
+
+	out := make([]string, 0, len(imgs))
+	for _, img := range imgs {
+		out = append(out, base64.StdEncoding.EncodeToString(img))
+	}
+	return out
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"bytes"
 	"context"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
 	"errors"
@@ -11,8 +12,10 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -20,16 +23,28 @@ const (
 	defaultGeminiBaseURL = "https://generativelanguage.googleapis.com/v1beta"
 	geminiStreamingPath  = ":streamGenerateContent"
 	geminiNonStreamPath  = ":generateContent"
-	geminiHTTPTimeout    = 2 * time.Minute
+	geminiHTTPTimeout    = 2 * time.Minute // default; see HTTPConfig.Timeout
 	httpStatusClientErr  = 400
 )
 
 // Gemini implements the Provider interface using Gemini-compatible REST endpoints.
 type Gemini struct {
-	client   *http.Client
-	baseURL  string
-	apiKey   string
-	authType string
+	client    *http.Client
+	baseURL   string
+	apiKey    string
+	authType  string
+	userAgent string
+
+	safetySettings []GeminiSafetySetting
+
+	// Images larger than uploadThreshold go through the Files API; uploads are kept by
+	// image hash so retries and fallbacks to this provider reuse them, and concurrent calls
+	// for the same bytes wait for the upload in progress.
+	uploadThreshold int64
+	pollInterval    time.Duration
+	uploadsMu       sync.Mutex
+	uploads         map[[sha256.Size]byte]geminiFile
+	uploading       map[[sha256.Size]byte]*geminiUpload
 }
 
 // NewGemini constructs a Gemini provider using the provided configuration.
@@ -50,11 +65,29 @@ func NewGemini(cfg ProviderConfig) (*Gemini, error) {
 
 	base := normalizeGeminiBaseURL(cfg.BaseURL)
 
+	client, err := cfg.HTTP.client(geminiHTTPTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	threshold := cfg.Gemini.UploadThreshold
+	if threshold == 0 {
+		threshold = defaultGeminiUploadThreshold
+	}
+
 	return &Gemini{
-		client:   &http.Client{Timeout: geminiHTTPTimeout},
-		baseURL:  base,
-		apiKey:   apiKey,
-		authType: authType,
+		client:    client,
+		baseURL:   base,
+		apiKey:    apiKey,
+		authType:  authType,
+		userAgent: cfg.HTTP.userAgent(),
+
+		safetySettings: cfg.Gemini.SafetySettings,
+
+		uploadThreshold: threshold,
+		pollInterval:    geminiFilePollInterval,
+		uploads:         map[[sha256.Size]byte]geminiFile{},
+		uploading:       map[[sha256.Size]byte]*geminiUpload{},
 	}, nil
 }
 
@@ -72,40 +105,141 @@ func normalizeGeminiBaseURL(base string) string {
 }
 
 // Chat sends a user prompt (with optional images) to a Gemini endpoint and streams responses if requested.
-func (g *Gemini) Chat(ctx context.Context, opts ChatOptions) error {
+func (g *Gemini) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
 	if g == nil || g.client == nil {
-		return fmt.Errorf("providers/gemini: nil client")
+		return Usage{}, fmt.Errorf("providers/gemini: nil client")
 	}
 
 	if strings.TrimSpace(opts.Model) == "" {
-		return fmt.Errorf("providers/gemini: model is required")
+		return Usage{}, fmt.Errorf("providers/gemini: model is required")
 	}
 
-	body, err := g.buildRequestBody(opts)
+	files, err := g.uploadLargeImages(ctx, opts)
 	if err != nil {
-		return err
+		return Usage{}, err
+	}
+
+	body, err := g.buildRequestBody(opts, files)
+	if err != nil {
+		return Usage{}, err
 	}
 
 	endpoint, err := g.buildEndpoint(opts.Model, opts.Stream)
 	if err != nil {
-		return err
+		return Usage{}, err
 	}
 
-	onDelta := onDeltaOrNoop(opts.OnDelta)
+	call := &geminiCall{onDelta: onDeltaOrNoop(opts.OnDelta)}
 	if opts.Stream {
-		return g.stream(ctx, endpoint, body, onDelta)
+		err = g.stream(ctx, endpoint, body, call)
+	} else {
+		err = g.nonStream(ctx, endpoint, body, call)
 	}
-	return g.nonStream(ctx, endpoint, body, onDelta)
+	return call.usage.withEstimatedImageTokens("gemini", opts.allImages()), err
 }
 
-func (g *Gemini) buildRequestBody(opts ChatOptions) ([]byte, error) {
-	contents, systemInstruction, err := g.buildContents(opts)
+// geminiCall carries the delta callback and accumulated state through the response readers.
+type geminiCall struct {
+	onDelta  func(string, string) error
+	usage    Usage
+	finished bool // a candidate reported a finishReason
+}
+
+// handle records usage from a response or stream chunk and emits its candidates.
+func (c *geminiCall) handle(resp *geminiGenerateResponse) error {
+	if resp.Error != nil {
+		return resp.Error
+	}
+
+	if resp.UsageMetadata != nil {
+		// Streams repeat cumulative usage on every chunk, so the latest one wins.
+		c.usage = resp.UsageMetadata.toUsage()
+	}
+	if err := emitGeminiCandidates(resp.Candidates, c.onDelta); err != nil {
+		return err
+	}
+
+	for _, cand := range resp.Candidates {
+		if cand.FinishReason != "" {
+			c.finished = true
+		}
+	}
+	return checkGeminiFinish(resp)
+}
+
+// GeminiBlockedError reports a response that Gemini refused or stopped before completing,
+// such as a prompt blocked by safety filters or a candidate that hit MAX_TOKENS.
+type GeminiBlockedError struct {
+	Reason     string   // blockReason or finishReason, e.g. "SAFETY", "RECITATION", "MAX_TOKENS"
+	Prompt     bool     // the prompt was blocked, so no candidate was generated
+	Message    string   // explanation from the API, when present
+	Categories []string // harm categories the API flagged as blocked
+}
+
+func (e *GeminiBlockedError) Error() string {
+	what := "response stopped"
+	if e.Prompt {
+		what = "prompt blocked"
+	}
+
+	msg := fmt.Sprintf("providers/gemini: %s: %s", what, e.Reason)
+	if len(e.Categories) > 0 {
+		msg += " (" + strings.Join(e.Categories, ", ") + ")"
+	}
+
+	if e.Message != "" {
+		msg += ": " + e.Message
+	}
+	return msg
+}
+
+// checkGeminiFinish turns a prompt block or an abnormal finishReason into a GeminiBlockedError.
+// Parts received before the stop have already been emitted.
+func checkGeminiFinish(resp *geminiGenerateResponse) error {
+	if fb := resp.PromptFeedback; fb != nil && fb.BlockReason != "" {
+		return &GeminiBlockedError{
+			Reason:     fb.BlockReason,
+			Prompt:     true,
+			Message:    fb.BlockReasonMessage,
+			Categories: blockedCategories(fb.SafetyRatings),
+		}
+	}
+
+	for _, cand := range resp.Candidates {
+		switch cand.FinishReason {
+		case "", "STOP", "FINISH_REASON_UNSPECIFIED":
+			continue
+		}
+
+		return &GeminiBlockedError{
+			Reason:     cand.FinishReason,
+			Message:    cand.FinishMessage,
+			Categories: blockedCategories(cand.SafetyRatings),
+		}
+	}
+	return nil
+}
+
+func blockedCategories(ratings []geminiSafetyRating) []string {
+	var out []string
+
+	for _, r := range ratings {
+		if r.Blocked {
+			out = append(out, r.Category)
+		}
+	}
+	return out
+}
+
+func (g *Gemini) buildRequestBody(opts ChatOptions, files map[[sha256.Size]byte]geminiFile) ([]byte, error) {
+	contents, systemInstruction, err := g.buildContents(opts, files)
 	if err != nil {
 		return nil, err
 	}
 
 	req := geminiGenerateRequest{
-		Contents: contents,
+		Contents:       contents,
+		SafetySettings: g.safetySettings,
 	}
 
 	if systemInstruction != nil {
@@ -126,42 +260,31 @@ func (g *Gemini) buildRequestBody(opts ChatOptions) ([]byte, error) {
 	return buf.Bytes(), nil
 }
 
-func (g *Gemini) buildContents(opts ChatOptions) ([]geminiContent, *geminiContent, error) {
-	// Pre-allocate: 1 for text prompt + N images
-	parts := make([]geminiPart, 0, 1+len(opts.Images))
-	if prompt := strings.TrimSpace(opts.Prompt); prompt != "" {
-		parts = append(parts, geminiPart{Text: prompt})
-	}
+func (g *Gemini) buildContents(
+	opts ChatOptions, files map[[sha256.Size]byte]geminiFile,
+) ([]geminiContent, *geminiContent, error) {
+	turns := opts.turns()
+	contents := make([]geminiContent, 0, len(turns))
 
-	for _, img := range opts.Images {
-		if len(img) == 0 {
+	for _, t := range turns {
+		parts := geminiParts(t, files)
+		if len(parts) == 0 {
 			continue
 		}
 
-		mime := http.DetectContentType(img)
-		if !strings.HasPrefix(mime, "image/") {
-			mime = "image/png"
+		// Gemini calls the assistant role "model".
+		role := "user"
+		if t.Role == RoleAssistant {
+			role = "model"
 		}
 
-		parts = append(parts, geminiPart{
-			InlineData: &geminiInlineData{
-				MimeType: mime,
-				Data:     base64.StdEncoding.EncodeToString(img),
-			},
-		})
+		contents = append(contents, geminiContent{Role: role, Parts: parts})
 	}
 
-	if len(parts) == 0 {
+	if len(contents) == 0 {
 		return nil, nil, fmt.Errorf("providers/gemini: prompt or images are required")
 	}
 
-	contents := []geminiContent{
-		{
-			Role:  "user",
-			Parts: parts,
-		},
-	}
-
 	var systemInstruction *geminiContent
 	if system := strings.TrimSpace(opts.SystemPrompt); system != "" {
 		systemInstruction = &geminiContent{
@@ -171,6 +294,46 @@ func (g *Gemini) buildContents(opts ChatOptions) ([]geminiContent, *geminiConten
 	return contents, systemInstruction, nil
 }
 
+// geminiParts converts one turn into a text part followed by image parts; images found in
+// files are referenced by URI, the rest are inlined.
+func geminiParts(t Message, files map[[sha256.Size]byte]geminiFile) []geminiPart {
+	// Pre-allocate: 1 for text prompt + N images
+	parts := make([]geminiPart, 0, 1+len(t.Images))
+	if text := strings.TrimSpace(t.Content); text != "" {
+		parts = append(parts, geminiPart{Text: text})
+	}
+
+	for _, img := range t.Images {
+		if len(img) == 0 {
+			continue
+		}
+
+		if len(files) > 0 {
+			if f, ok := files[sha256.Sum256(img)]; ok {
+				parts = append(parts, geminiPart{FileData: &geminiFileData{MimeType: f.MimeType, FileURI: f.URI}})
+				continue
+			}
+		}
+
+		parts = append(parts, geminiPart{
+			InlineData: &geminiInlineData{
+				MimeType: geminiImageMIME(img),
+				Data:     base64.StdEncoding.EncodeToString(img),
+			},
+		})
+	}
+	return parts
+}
+
+// geminiImageMIME sniffs the image type, defaulting to PNG.
+func geminiImageMIME(img []byte) string {
+	mime := http.DetectContentType(img)
+	if !strings.HasPrefix(mime, "image/") {
+		mime = "image/png"
+	}
+	return mime
+}
+
 func (g *Gemini) buildEndpoint(model string, stream bool) (string, error) {
 	model = strings.TrimSpace(model)
 	if model == "" {
@@ -204,6 +367,23 @@ func buildGenerationConfig(opts ChatOptions) *geminiGenerationConfig {
 		cfg.TopK = topK
 	}
 
+	if opts.MaxTokens > 0 {
+		cfg.MaxOutputTokens = intPtr(opts.MaxTokens)
+	}
+
+	cfg.Seed = opts.Seed
+	cfg.StopSequences = opts.stop()
+
+	switch {
+	case opts.thinkingEnabled():
+		cfg.ThinkingConfig = &geminiThinkingConfig{
+			ThinkingBudget:  intPtr(opts.thinkingBudget()),
+			IncludeThoughts: true,
+		}
+	case opts.reasoningEffort() == "none":
+		cfg.ThinkingConfig = &geminiThinkingConfig{ThinkingBudget: intPtr(0)}
+	}
+
 	if opts.NoResponseFormat {
 		if cfg.isEmpty() {
 			return nil
@@ -279,13 +459,12 @@ func (cfg *geminiGenerationConfig) isEmpty() bool {
 		return true
 	}
 	return cfg.Temperature == nil && cfg.TopP == nil && cfg.TopK == nil &&
-		cfg.ResponseMimeType == "" && cfg.ResponseSchema == nil
+		cfg.MaxOutputTokens == nil && cfg.Seed == nil && len(cfg.StopSequences) == 0 &&
+		cfg.ResponseMimeType == "" && cfg.ResponseSchema == nil && cfg.ThinkingConfig == nil
 }
 
-func (g *Gemini) stream(
-	ctx context.Context, endpoint string, body []byte, onDelta func(string, string) error,
-) error {
-	req, err := g.newRequest(ctx, endpoint, body)
+func (g *Gemini) stream(ctx context.Context, endpoint string, body []byte, call *geminiCall) error {
+	req, err := g.newRequest(ctx, http.MethodPost, endpoint, body)
 	if err != nil {
 		return err
 	}
@@ -302,10 +481,10 @@ func (g *Gemini) stream(
 		return err
 	}
 
-	return g.readSSEStream(resp.Body, onDelta)
+	return g.readSSEStream(resp.Body, call)
 }
 
-func (g *Gemini) readSSEStream(body io.Reader, onDelta func(string, string) error) error {
+func (g *Gemini) readSSEStream(body io.Reader, call *geminiCall) error {
 	reader := bufio.NewReader(body)
 
 	var dataBuf bytes.Buffer
@@ -313,30 +492,34 @@ func (g *Gemini) readSSEStream(body io.Reader, onDelta func(string, string) erro
 	for {
 		line, err := reader.ReadString('\n')
 		if err != nil {
-			return g.handleSSEReadError(err, &dataBuf, onDelta)
+			return g.handleSSEReadError(err, &dataBuf, call)
 		}
 
-		if done, err := g.processSSELine(line, &dataBuf, onDelta); done || err != nil {
+		if done, err := g.processSSELine(line, &dataBuf, call); done || err != nil {
 			return err
 		}
 	}
 }
 
-func (g *Gemini) handleSSEReadError(
-	err error, dataBuf *bytes.Buffer, onDelta func(string, string) error,
-) error {
+func (g *Gemini) handleSSEReadError(err error, dataBuf *bytes.Buffer, call *geminiCall) error {
 	if errors.Is(err, io.EOF) {
-		if dataBuf.Len() == 0 {
-			return nil
+		if dataBuf.Len() > 0 {
+			if err := g.dispatchSSEData(dataBuf.String(), call); err != nil {
+				return err
+			}
+		}
+
+		// The last chunk of a complete stream carries the finishReason; without it the
+		// connection was cut.
+		if !call.finished {
+			return fmt.Errorf("providers/gemini: stream ended before finishReason: %w", io.ErrUnexpectedEOF)
 		}
-		return g.dispatchSSEData(dataBuf.String(), onDelta)
+		return nil
 	}
 	return fmt.Errorf("providers/gemini: read stream: %w", err)
 }
 
-func (g *Gemini) processSSELine(
-	line string, dataBuf *bytes.Buffer, onDelta func(string, string) error,
-) (bool, error) {
+func (g *Gemini) processSSELine(line string, dataBuf *bytes.Buffer, call *geminiCall) (bool, error) {
 	line = strings.TrimRight(line, "\r\n")
 
 	if strings.HasPrefix(line, "data:") {
@@ -351,7 +534,7 @@ func (g *Gemini) processSSELine(
 	}
 
 	if line == "" && dataBuf.Len() > 0 {
-		if err := g.dispatchSSEData(dataBuf.String(), onDelta); err != nil {
+		if err := g.dispatchSSEData(dataBuf.String(), call); err != nil {
 			return true, err
 		}
 
@@ -361,7 +544,7 @@ func (g *Gemini) processSSELine(
 	return false, nil
 }
 
-func (g *Gemini) dispatchSSEData(payload string, onDelta func(string, string) error) error {
+func (g *Gemini) dispatchSSEData(payload string, call *geminiCall) error {
 	trimmed := strings.TrimSpace(payload)
 	if trimmed == "" || trimmed == "[DONE]" {
 		return nil
@@ -371,17 +554,11 @@ func (g *Gemini) dispatchSSEData(payload string, onDelta func(string, string) er
 	if err := json.Unmarshal([]byte(trimmed), &chunk); err != nil {
 		return fmt.Errorf("providers/gemini: decode stream chunk: %w", err)
 	}
-
-	if chunk.Error != nil {
-		return chunk.Error
-	}
-	return emitGeminiCandidates(chunk.Candidates, onDelta)
+	return call.handle(&chunk)
 }
 
-func (g *Gemini) nonStream(
-	ctx context.Context, endpoint string, body []byte, onDelta func(string, string) error,
-) error {
-	req, err := g.newRequest(ctx, endpoint, body)
+func (g *Gemini) nonStream(ctx context.Context, endpoint string, body []byte, call *geminiCall) error {
+	req, err := g.newRequest(ctx, http.MethodPost, endpoint, body)
 	if err != nil {
 		return err
 	}
@@ -405,17 +582,17 @@ func (g *Gemini) nonStream(
 		return fmt.Errorf("providers/gemini: decode response: %w", err)
 	}
 
-	return emitGeminiCandidates(out.Candidates, onDelta)
+	return call.handle(&out)
 }
 
-func (g *Gemini) newRequest(ctx context.Context, endpoint string, body []byte) (*http.Request, error) {
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, g.attachAPIKey(endpoint), bytes.NewReader(body))
+func (g *Gemini) newRequest(ctx context.Context, method, endpoint string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(ctx, method, g.attachAPIKey(endpoint), bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("providers/gemini: build request: %w", err)
 	}
 
 	req.Header.Set("Content-Type", "application/json")
-	req.Header.Set("User-Agent", browserUserAgent)
+	req.Header.Set("User-Agent", g.userAgent)
 
 	if g.authType == "auth_token" {
 		req.Header.Set("Authorization", "Bearer "+g.apiKey)
@@ -442,11 +619,16 @@ func checkGeminiResponse(resp *http.Response) error {
 	body, _ := io.ReadAll(resp.Body)
 
 	var apiErr geminiError
-	if err := json.Unmarshal(body, &apiErr); err == nil && apiErr.Error != nil {
-		return apiErr.Error
+	if err := json.Unmarshal(body, &apiErr); err != nil || apiErr.Error == nil {
+		apiErr.Error = &geminiErrorPayload{
+			Code:    resp.StatusCode,
+			Message: strings.TrimSpace(string(body)),
+		}
 	}
 
-	return fmt.Errorf("providers/gemini: http %d: %s", resp.StatusCode, strings.TrimSpace(string(body)))
+	apiErr.Error.HTTPStatus = resp.StatusCode
+	apiErr.Error.RetryAfter = parseRetryAfter(resp.Header)
+	return apiErr.Error
 }
 
 func emitGeminiCandidates(candidates []geminiCandidate, onDelta func(string, string) error) error {
@@ -456,7 +638,12 @@ func emitGeminiCandidates(candidates []geminiCandidate, onDelta func(string, str
 				continue
 			}
 
-			if err := onDelta(part.Text, ""); err != nil {
+			content, thinking := part.Text, ""
+			if part.Thought {
+				content, thinking = "", part.Text
+			}
+
+			if err := onDelta(content, thinking); err != nil {
 				return err
 			}
 		}
@@ -468,11 +655,53 @@ type geminiGenerateRequest struct {
 	Contents          []geminiContent         `json:"contents"`
 	SystemInstruction *geminiContent          `json:"system_instruction,omitempty"`
 	GenerationConfig  *geminiGenerationConfig `json:"generationConfig,omitempty"`
+	SafetySettings    []GeminiSafetySetting   `json:"safetySettings,omitempty"`
 }
 
 type geminiGenerateResponse struct {
-	Candidates []geminiCandidate   `json:"candidates"`
-	Error      *geminiErrorPayload `json:"error"`
+	Candidates     []geminiCandidate     `json:"candidates"`
+	PromptFeedback *geminiPromptFeedback `json:"promptFeedback"`
+	UsageMetadata  *geminiUsageMetadata  `json:"usageMetadata"`
+	Error          *geminiErrorPayload   `json:"error"`
+}
+
+type geminiPromptFeedback struct {
+	BlockReason        string               `json:"blockReason"`
+	BlockReasonMessage string               `json:"blockReasonMessage"`
+	SafetyRatings      []geminiSafetyRating `json:"safetyRatings"`
+}
+
+type geminiSafetyRating struct {
+	Category    string `json:"category"`
+	Probability string `json:"probability"`
+	Blocked     bool   `json:"blocked"`
+}
+
+type geminiUsageMetadata struct {
+	PromptTokenCount     int                      `json:"promptTokenCount"`
+	CandidatesTokenCount int                      `json:"candidatesTokenCount"`
+	ThoughtsTokenCount   int                      `json:"thoughtsTokenCount"`
+	PromptTokensDetails  []geminiModalityTokenCnt `json:"promptTokensDetails"`
+}
+
+type geminiModalityTokenCnt struct {
+	Modality   string `json:"modality"`
+	TokenCount int    `json:"tokenCount"`
+}
+
+func (m *geminiUsageMetadata) toUsage() Usage {
+	u := Usage{
+		PromptTokens:     m.PromptTokenCount,
+		CompletionTokens: m.CandidatesTokenCount,
+		ThinkingTokens:   m.ThoughtsTokenCount,
+	}
+
+	for _, d := range m.PromptTokensDetails {
+		if d.Modality == "IMAGE" {
+			u.ImageTokens += d.TokenCount
+		}
+	}
+	return u
 }
 
 type geminiContent struct {
@@ -482,7 +711,9 @@ type geminiContent struct {
 
 type geminiPart struct {
 	Text       string            `json:"text,omitempty"`
+	Thought    bool              `json:"thought,omitempty"`
 	InlineData *geminiInlineData `json:"inline_data,omitempty"`
+	FileData   *geminiFileData   `json:"file_data,omitempty"`
 }
 
 type geminiInlineData struct {
@@ -490,16 +721,34 @@ type geminiInlineData struct {
 	Data     string `json:"data"`
 }
 
+type geminiFileData struct {
+	MimeType string `json:"mime_type"`
+	FileURI  string `json:"file_uri"`
+}
+
 type geminiGenerationConfig struct {
 	Temperature      *float64 `json:"temperature,omitempty"`
 	TopP             *float64 `json:"topP,omitempty"`
 	TopK             *int     `json:"topK,omitempty"`
+	MaxOutputTokens  *int     `json:"maxOutputTokens,omitempty"`
+	Seed             *int64   `json:"seed,omitempty"`
+	StopSequences    []string `json:"stopSequences,omitempty"`
 	ResponseMimeType string   `json:"responseMimeType,omitempty"`
 	ResponseSchema   any      `json:"responseSchema,omitempty"`
+
+	ThinkingConfig *geminiThinkingConfig `json:"thinkingConfig,omitempty"`
+}
+
+type geminiThinkingConfig struct {
+	ThinkingBudget  *int `json:"thinkingBudget,omitempty"`
+	IncludeThoughts bool `json:"includeThoughts,omitempty"`
 }
 
 type geminiCandidate struct {
-	Content geminiContent `json:"content"`
+	Content       geminiContent        `json:"content"`
+	FinishReason  string               `json:"finishReason"`
+	FinishMessage string               `json:"finishMessage"`
+	SafetyRatings []geminiSafetyRating `json:"safetyRatings"`
 }
 
 type geminiError struct {
@@ -510,6 +759,9 @@ type geminiErrorPayload struct {
 	Code    int    `json:"code"`
 	Status  string `json:"status"`
 	Message string `json:"message"`
+
+	HTTPStatus int           `json:"-"`
+	RetryAfter time.Duration `json:"-"`
 }
 
 func (e *geminiErrorPayload) Error() string {
@@ -526,3 +778,69 @@ func (e *geminiErrorPayload) Error() string {
 	}
 	return fmt.Sprintf("providers/gemini: %s", e.Message)
 }
+
+// geminiModelList is one page of models.list.
+type geminiModelList struct {
+	Models []struct {
+		Name                       string   `json:"name"`
+		DisplayName                string   `json:"displayName"`
+		SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
+	} `json:"models"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// ListModels implements ModelLister using models.list, keeping models that support
+// generateContent. The API does not report input modalities.
+func (g *Gemini) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	var (
+		models []ModelInfo
+		token  string
+	)
+
+	for {
+		endpoint := strings.TrimRight(g.baseURL, "/") + "/models?pageSize=1000"
+		if token != "" {
+			endpoint += "&pageToken=" + url.QueryEscape(token)
+		}
+
+		page, err := g.listModelsPage(ctx, endpoint)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, m := range page.Models {
+			if slices.Contains(m.SupportedGenerationMethods, "generateContent") {
+				models = append(models, ModelInfo{ID: strings.TrimPrefix(m.Name, "models/"), DisplayName: m.DisplayName})
+			}
+		}
+
+		if token = page.NextPageToken; token == "" {
+			return models, nil
+		}
+	}
+}
+
+func (g *Gemini) listModelsPage(ctx context.Context, endpoint string) (*geminiModelList, error) {
+	req, err := g.newRequest(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("providers/gemini: list models: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkGeminiResponse(resp); err != nil {
+		return nil, err
+	}
+
+	var page geminiModelList
+	if err := json.NewDecoder(resp.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("providers/gemini: decode models: %w", err)
+	}
+	return &page, nil
+}
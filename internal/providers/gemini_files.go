@@ -0,0 +1,244 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// Inline requests are capped at 20 MB and base64 grows images by a third.
+	defaultGeminiUploadThreshold = 10 << 20
+	geminiFilePollInterval       = time.Second
+	geminiFileMaxPolls           = 60
+	// geminiFileReuseMargin re-uploads files this close to expiry instead of referencing them.
+	geminiFileReuseMargin = time.Hour
+)
+
+// geminiFile is a file resource returned by the Files API.
+type geminiFile struct {
+	Name           string `json:"name"` // "files/<id>"
+	URI            string `json:"uri"`
+	MimeType       string `json:"mimeType"`
+	State          string `json:"state"` // PROCESSING, ACTIVE or FAILED
+	ExpirationTime string `json:"expirationTime"`
+}
+
+// usable reports whether f can still be referenced from a request.
+func (f geminiFile) usable() bool {
+	if f.URI == "" {
+		return false
+	}
+
+	exp, err := time.Parse(time.RFC3339, f.ExpirationTime)
+	return err != nil || time.Until(exp) > geminiFileReuseMargin
+}
+
+// uploadLargeImages uploads every image above the threshold through the Files API and
+// returns them by image hash. Earlier uploads of the same bytes are reused.
+func (g *Gemini) uploadLargeImages(ctx context.Context, opts ChatOptions) (map[[sha256.Size]byte]geminiFile, error) {
+	if g.uploadThreshold < 0 {
+		return nil, nil
+	}
+
+	var files map[[sha256.Size]byte]geminiFile
+
+	for _, img := range opts.allImages() {
+		if int64(len(img)) <= g.uploadThreshold {
+			continue
+		}
+
+		sum := sha256.Sum256(img)
+		if _, ok := files[sum]; ok {
+			continue
+		}
+
+		f, err := g.uploadedFile(ctx, sum, img)
+		if err != nil {
+			return nil, err
+		}
+
+		if files == nil {
+			files = map[[sha256.Size]byte]geminiFile{}
+		}
+		files[sum] = f
+	}
+	return files, nil
+}
+
+// geminiUpload is an upload in progress; done is closed once file or err is set.
+type geminiUpload struct {
+	done chan struct{}
+	file geminiFile
+	err  error
+}
+
+// uploadedFile returns the stored upload for sum, uploading img when there is none. Calls
+// for bytes already being uploaded wait for that upload instead of starting another.
+func (g *Gemini) uploadedFile(ctx context.Context, sum [sha256.Size]byte, img []byte) (geminiFile, error) {
+	for {
+		g.uploadsMu.Lock()
+
+		if f, ok := g.uploads[sum]; ok && f.usable() {
+			g.uploadsMu.Unlock()
+			return f, nil
+		}
+
+		if up, ok := g.uploading[sum]; ok {
+			g.uploadsMu.Unlock()
+
+			select {
+			case <-ctx.Done():
+				return geminiFile{}, ctx.Err()
+			case <-up.done:
+			}
+
+			// A canceled uploader says nothing about this call, which takes over instead.
+			if up.err != nil && (errors.Is(up.err, context.Canceled) || errors.Is(up.err, context.DeadlineExceeded)) {
+				continue
+			}
+			return up.file, up.err
+		}
+
+		up := &geminiUpload{done: make(chan struct{})}
+		g.uploading[sum] = up
+		g.uploadsMu.Unlock()
+
+		up.file, up.err = g.uploadFile(ctx, img, fmt.Sprintf("dino-%x", sum[:8]))
+
+		g.uploadsMu.Lock()
+		delete(g.uploading, sum)
+
+		if up.err == nil {
+			g.uploads[sum] = up.file
+		}
+		g.uploadsMu.Unlock()
+		close(up.done)
+
+		return up.file, up.err
+	}
+}
+
+// uploadFile runs a resumable upload: a start request announcing size and type, then one
+// "upload, finalize" request with the bytes sent to the session URL.
+func (g *Gemini) uploadFile(ctx context.Context, img []byte, displayName string) (geminiFile, error) {
+	mime := geminiImageMIME(img)
+
+	meta, _ := json.Marshal(map[string]any{"file": map[string]string{"display_name": displayName}})
+
+	req, err := g.newRequest(ctx, http.MethodPost, g.uploadEndpoint(), meta)
+	if err != nil {
+		return geminiFile{}, err
+	}
+
+	req.Header.Set("X-Goog-Upload-Protocol", "resumable")
+	req.Header.Set("X-Goog-Upload-Command", "start")
+	req.Header.Set("X-Goog-Upload-Header-Content-Length", strconv.Itoa(len(img)))
+	req.Header.Set("X-Goog-Upload-Header-Content-Type", mime)
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return geminiFile{}, fmt.Errorf("providers/gemini: start upload: %w", err)
+	}
+
+	err = checkGeminiResponse(resp)
+	resp.Body.Close()
+
+	if err != nil {
+		return geminiFile{}, err
+	}
+
+	session := resp.Header.Get("X-Goog-Upload-URL")
+	if session == "" {
+		return geminiFile{}, fmt.Errorf("providers/gemini: start upload: no upload URL in response")
+	}
+
+	// The session URL is already authorized, so the API key is not attached again.
+	req, err = http.NewRequestWithContext(ctx, http.MethodPost, session, bytes.NewReader(img))
+	if err != nil {
+		return geminiFile{}, fmt.Errorf("providers/gemini: build upload request: %w", err)
+	}
+
+	req.Header.Set("User-Agent", g.userAgent)
+	req.Header.Set("X-Goog-Upload-Offset", "0")
+	req.Header.Set("X-Goog-Upload-Command", "upload, finalize")
+
+	f, err := g.doFileRequest(req)
+	if err != nil {
+		return geminiFile{}, err
+	}
+	return g.waitForFile(ctx, f)
+}
+
+// waitForFile polls a file still in PROCESSING until it becomes ACTIVE.
+func (g *Gemini) waitForFile(ctx context.Context, f geminiFile) (geminiFile, error) {
+	for polls := 0; f.State == "PROCESSING"; polls++ {
+		if polls >= geminiFileMaxPolls {
+			return geminiFile{}, fmt.Errorf("providers/gemini: %s is still processing", f.Name)
+		}
+
+		select {
+		case <-ctx.Done():
+			return geminiFile{}, ctx.Err()
+		case <-time.After(g.pollInterval):
+		}
+
+		endpoint := strings.TrimRight(g.baseURL, "/") + "/" + f.Name
+
+		req, err := g.newRequest(ctx, http.MethodGet, endpoint, nil)
+		if err != nil {
+			return geminiFile{}, err
+		}
+
+		if f, err = g.doFileRequest(req); err != nil {
+			return geminiFile{}, err
+		}
+	}
+
+	if f.State == "FAILED" || f.URI == "" {
+		return geminiFile{}, fmt.Errorf("providers/gemini: upload of %s failed (state %s)", f.Name, f.State)
+	}
+	return f, nil
+}
+
+// doFileRequest sends req and decodes a file resource, bare or wrapped in {"file": ...}.
+func (g *Gemini) doFileRequest(req *http.Request) (geminiFile, error) {
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return geminiFile{}, fmt.Errorf("providers/gemini: file request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if err := checkGeminiResponse(resp); err != nil {
+		return geminiFile{}, err
+	}
+
+	var out struct {
+		geminiFile
+
+		File *geminiFile `json:"file"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return geminiFile{}, fmt.Errorf("providers/gemini: decode file: %w", err)
+	}
+
+	if out.File != nil {
+		return *out.File, nil
+	}
+	return out.geminiFile, nil
+}
+
+// uploadEndpoint maps the API base (".../v1beta") to its media upload path
+// (".../upload/v1beta/files").
+func (g *Gemini) uploadEndpoint() string {
+	base := strings.TrimRight(g.baseURL, "/")
+	i := strings.LastIndex(base, "/")
+	return base[:i] + "/upload" + base[i:] + "/files"
+}
@@ -0,0 +1,233 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	mockDefaultMaxBoxes  = 5
+	mockDefaultChunkSize = 16
+	mockMinBoxFraction   = 0.05
+	mockMaxBoxFraction   = 0.5
+)
+
+// MockConfig controls the mock provider.
+type MockConfig struct {
+	Fixtures    string        // directory of <image path>.json responses returned verbatim
+	Classes     []string      // labels for generated boxes; "object" when empty
+	BboxScale   int           // emit boxes normalized to this scale; 0 uses pixel coordinates
+	MaxBoxes    int           // upper bound of generated boxes per image; 0 uses 5
+	Seed        uint64        // mixed with the image hash so runs are reproducible
+	ChunkSize   int           // characters per streamed chunk; 0 uses 16
+	ChunkDelay  time.Duration // pause between streamed chunks
+	ErrorRate   float64       // fraction of calls that fail, in [0, 1]
+	ErrorStatus int           // HTTP status of injected failures; 0 cuts the stream mid-response
+}
+
+// mockError is an injected failure carrying an HTTP status so retry and fallback paths apply.
+type mockError struct {
+	StatusCode int
+}
+
+func (e *mockError) Error() string {
+	return fmt.Sprintf("providers/mock: injected error: %d %s", e.StatusCode, http.StatusText(e.StatusCode))
+}
+
+// Mock is an offline Provider that answers with fixture or generated detections.
+type Mock struct {
+	cfg   MockConfig
+	calls atomic.Uint64
+}
+
+// NewMock constructs a mock provider. It never touches the network.
+func NewMock(cfg ProviderConfig) (*Mock, error) {
+	m := cfg.Mock
+	if m.MaxBoxes <= 0 {
+		m.MaxBoxes = mockDefaultMaxBoxes
+	}
+
+	if m.ChunkSize <= 0 {
+		m.ChunkSize = mockDefaultChunkSize
+	}
+
+	if m.ErrorRate < 0 || m.ErrorRate > 1 {
+		return nil, fmt.Errorf("providers/mock: errorRate must be between 0 and 1, got %v", m.ErrorRate)
+	}
+	return &Mock{cfg: m}, nil
+}
+
+// Chat implements Provider. The response is a JSON array of {label, bbox} detections: the
+// fixture named after the first image when one exists, otherwise random boxes seeded by the
+// image bytes. With Stream set the content is delivered in ChunkSize pieces.
+func (m *Mock) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	rng := rand.New(rand.NewPCG(m.cfg.Seed, m.calls.Add(1)))
+	fail := m.cfg.ErrorRate > 0 && rng.Float64() < m.cfg.ErrorRate
+
+	switch {
+	case fail && m.cfg.ErrorStatus != 0:
+		return Usage{}, &mockError{StatusCode: m.cfg.ErrorStatus}
+	case fail && !opts.Stream:
+		return Usage{}, fmt.Errorf("providers/mock: injected connection drop: %w", io.ErrUnexpectedEOF)
+	}
+
+	content, err := m.response(opts)
+	if err != nil {
+		return Usage{}, err
+	}
+
+	usage := Usage{
+		PromptTokens:     EstimateRequestTokens("mock", opts),
+		CompletionTokens: (len(content) + charsPerToken - 1) / charsPerToken,
+	}.withEstimatedImageTokens("mock", opts.allImages())
+
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+	if opts.thinkingEnabled() {
+		if err := onDelta("", "mock provider: no reasoning performed\n"); err != nil {
+			return usage, err
+		}
+	}
+
+	if !opts.Stream {
+		return usage, onDelta(content, "")
+	}
+
+	for i := 0; i < len(content); i += m.cfg.ChunkSize {
+		// A failure without a status simulates a connection dropped halfway through the stream.
+		if fail && (i >= len(content)/2 || i+m.cfg.ChunkSize >= len(content)) {
+			return usage, fmt.Errorf("providers/mock: injected stream cut: %w", io.ErrUnexpectedEOF)
+		}
+
+		if err := m.pause(ctx); err != nil {
+			return usage, err
+		}
+
+		if err := onDelta(content[i:min(i+m.cfg.ChunkSize, len(content))], ""); err != nil {
+			return usage, err
+		}
+	}
+	return usage, nil
+}
+
+// response returns the fixture for the first image, or generated detections.
+func (m *Mock) response(opts ChatOptions) (string, error) {
+	var img []byte
+	if len(opts.Images) > 0 {
+		img = opts.Images[0]
+	}
+
+	if m.cfg.Fixtures != "" && len(opts.ImageNames) > 0 {
+		if b, ok, err := m.fixture(opts.ImageNames[0]); err != nil || ok {
+			return string(b), err
+		}
+	}
+
+	out, err := json.Marshal(m.generate(img))
+	if err != nil {
+		return "", fmt.Errorf("providers/mock: encode detections: %w", err)
+	}
+	return string(out), nil
+}
+
+// fixture looks up <name>.json, then <name without extension>.json, in the fixture directory.
+// name is tried as the path relative to the input first, so a/x.jpg and b/x.jpg can have
+// their own fixtures, then as the bare file name.
+func (m *Mock) fixture(name string) ([]byte, bool, error) {
+	rel := filepath.Clean(filepath.FromSlash(name))
+
+	names := []string{filepath.Base(rel)}
+	if filepath.IsLocal(rel) && rel != names[0] {
+		names = append([]string{rel}, names...)
+	}
+
+	for _, n := range names {
+		for _, candidate := range []string{n + ".json", strings.TrimSuffix(n, filepath.Ext(n)) + ".json"} {
+			b, err := os.ReadFile(filepath.Join(m.cfg.Fixtures, candidate))
+			if errors.Is(err, os.ErrNotExist) {
+				continue
+			}
+
+			if err != nil {
+				return nil, false, fmt.Errorf("providers/mock: read fixture: %w", err)
+			}
+			return b, true, nil
+		}
+	}
+	return nil, false, nil
+}
+
+// mockDetection mirrors the detection shape the run command expects.
+type mockDetection struct {
+	Label string `json:"label"`
+	BBox  [4]int `json:"bbox"`
+}
+
+// generate returns up to MaxBoxes random boxes inside the image, seeded by its bytes.
+func (m *Mock) generate(img []byte) []mockDetection {
+	sum := sha256.Sum256(img)
+	rng := rand.New(rand.NewPCG(m.cfg.Seed, binary.BigEndian.Uint64(sum[:8])))
+
+	w, h := m.cfg.BboxScale, m.cfg.BboxScale
+	if w <= 0 {
+		w, h = 0, 0
+		if cfg, _, err := image.DecodeConfig(bytes.NewReader(img)); err == nil {
+			w, h = cfg.Width, cfg.Height
+		}
+	}
+
+	if w <= 0 || h <= 0 {
+		return []mockDetection{}
+	}
+
+	classes := m.cfg.Classes
+	if len(classes) == 0 {
+		classes = []string{"object"}
+	}
+
+	n := rng.IntN(m.cfg.MaxBoxes + 1)
+	dets := make([]mockDetection, 0, n)
+
+	for range n {
+		bw := int(float64(w) * (mockMinBoxFraction + rng.Float64()*(mockMaxBoxFraction-mockMinBoxFraction)))
+		bh := int(float64(h) * (mockMinBoxFraction + rng.Float64()*(mockMaxBoxFraction-mockMinBoxFraction)))
+		x1 := rng.IntN(max(w-bw, 1))
+		y1 := rng.IntN(max(h-bh, 1))
+
+		dets = append(dets, mockDetection{
+			Label: classes[rng.IntN(len(classes))],
+			BBox:  [4]int{x1, y1, x1 + bw, y1 + bh},
+		})
+	}
+	return dets
+}
+
+// pause waits ChunkDelay between streamed chunks, honoring cancellation.
+func (m *Mock) pause(ctx context.Context) error {
+	if m.cfg.ChunkDelay <= 0 {
+		return ctx.Err()
+	}
+
+	timer := time.NewTimer(m.cfg.ChunkDelay)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,38 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrModelListUnsupported is returned by providers whose API cannot enumerate the models a
+// request may name, such as Azure, where deployments are managed outside the data plane.
+var ErrModelListUnsupported = errors.New("listing models is not supported")
+
+// ModelInfo describes one model offered by a provider.
+type ModelInfo struct {
+	ID          string // name to use as `model`
+	DisplayName string // human-readable name when the API provides one
+	Vision      *bool  // image input support; nil when the API does not report it
+}
+
+// ModelLister is implemented by providers that can enumerate their models.
+type ModelLister interface {
+	ListModels(ctx context.Context) ([]ModelInfo, error)
+}
+
+// FindModel looks up name in models, accepting Ollama's implicit ":latest" tag and
+// Gemini's "models/" prefix.
+func FindModel(models []ModelInfo, name string) (ModelInfo, bool) {
+	name = strings.TrimPrefix(strings.TrimSpace(name), "models/")
+
+	for _, m := range models {
+		if m.ID == name || (!strings.Contains(name, ":") && m.ID == name+":latest") {
+			return m, true
+		}
+	}
+	return ModelInfo{}, false
+}
+
+func boolPtr(b bool) *bool { return &b }
@@ -4,15 +4,26 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/ollama/ollama/api"
+	"github.com/ollama/ollama/envconfig"
 )
 
 // Ollama wraps an Ollama API client.
-// Use NewOllamaFromEnv() to construct from environment variables (OLLAMA_HOST, etc.).
+// Use NewOllamaFromConfig() to honor ProviderConfig, or NewOllamaFromEnv() to construct
+// from environment variables (OLLAMA_HOST, etc.).
 type Ollama struct {
-	client *api.Client
+	client    *api.Client
+	keepAlive *api.Duration
+	options   map[string]any
 }
 
 // NewOllama creates a provider from an existing client instance.
@@ -29,6 +40,114 @@ func NewOllamaFromEnv() (*Ollama, error) {
 	return &Ollama{client: c}, nil
 }
 
+// NewOllamaFromConfig creates a provider from ProviderConfig. BaseURL falls back to OLLAMA_HOST,
+// and a non-empty APIKey is sent as a bearer token (or X-Api-Key when AuthType is "api_key")
+// so remote hosts behind an authenticating proxy can be reached. Unlike the other providers,
+// an empty AuthType means "auth_token", which is what ollama.com and most proxies expect.
+func NewOllamaFromConfig(cfg ProviderConfig) (*Ollama, error) {
+	base, err := ollamaBaseURL(cfg.BaseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	// The Ollama client sends its own User-Agent, so only an explicit override replaces it.
+	httpCfg := cfg.HTTP
+	if ua := strings.TrimSpace(httpCfg.UserAgent); ua != "" {
+		httpCfg.Headers = maps.Clone(httpCfg.Headers)
+		if httpCfg.Headers == nil {
+			httpCfg.Headers = map[string]string{}
+		}
+		httpCfg.Headers["User-Agent"] = ua
+	}
+
+	// Local generations can take minutes, so there is no default timeout.
+	httpClient, err := httpCfg.client(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := strings.TrimSpace(cfg.APIKey); key != "" {
+		authType := strings.ToLower(strings.TrimSpace(cfg.AuthType))
+		if authType == "" {
+			authType = "auth_token"
+		}
+
+		if authType != "api_key" && authType != "auth_token" {
+			return nil, fmt.Errorf("providers/ollama: unsupported auth type %q", cfg.AuthType)
+		}
+
+		httpClient.Transport = &ollamaAuthTransport{base: httpClient.Transport, key: key, authType: authType}
+	}
+
+	keepAlive, err := parseKeepAlive(cfg.Ollama.KeepAlive)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Ollama{
+		client:    api.NewClient(base, httpClient),
+		keepAlive: keepAlive,
+		options:   cfg.Ollama.options(),
+	}, nil
+}
+
+// ollamaBaseURL parses a configured base URL, accepting bare host:port values and an optional
+// trailing /api (the client appends API paths itself).
+func ollamaBaseURL(raw string) (*url.URL, error) {
+	s := strings.TrimRight(strings.TrimSpace(raw), "/")
+	if s == "" {
+		return envconfig.Host(), nil
+	}
+
+	if !strings.Contains(s, "://") {
+		s = "http://" + s
+	}
+
+	s = strings.TrimSuffix(s, "/api")
+
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("providers/ollama: invalid base url %q", raw)
+	}
+	return u, nil
+}
+
+// parseKeepAlive accepts Go durations ("5m", "1h") or plain seconds ("300", "-1" keeps the model loaded).
+func parseKeepAlive(s string) (*api.Duration, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, nil
+	}
+
+	if secs, err := strconv.Atoi(s); err == nil {
+		return &api.Duration{Duration: time.Duration(secs) * time.Second}, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return nil, fmt.Errorf("providers/ollama: invalid keep_alive %q: %w", s, err)
+	}
+	return &api.Duration{Duration: d}, nil
+}
+
+// ollamaAuthTransport injects credentials into every request unless the client already set them.
+type ollamaAuthTransport struct {
+	base     http.RoundTripper
+	key      string
+	authType string
+}
+
+func (t *ollamaAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+
+	if t.authType == "api_key" {
+		r.Header.Set("X-Api-Key", t.key)
+	} else if r.Header.Get("Authorization") == "" {
+		r.Header.Set("Authorization", "Bearer "+t.key)
+	}
+	return t.base.RoundTrip(r)
+}
+
 // toAPIImages converts [][]byte to []api.ImageData.
 func toAPIImages(imgs [][]byte) []api.ImageData {
 	if len(imgs) == 0 {
@@ -42,11 +161,16 @@ func toAPIImages(imgs [][]byte) []api.ImageData {
 	return out
 }
 
-// mergeOptions builds the options map, only setting non-zero values and letting user options override.
-func mergeOptions(opts ChatOptions) map[string]any {
+// mergeOptions builds the options map, only setting non-zero values. Provider defaults are applied
+// first and per-call user options override both.
+func mergeOptions(opts ChatOptions, defaults map[string]any) map[string]any {
 	m := map[string]any{
-		"enable_thinking": opts.Think,
+		"enable_thinking": opts.thinkingEnabled(),
 	}
+	for k, v := range defaults {
+		m[k] = v
+	}
+
 	if opts.Temperature != 0 {
 		m["temperature"] = opts.Temperature
 	}
@@ -55,12 +179,34 @@ func mergeOptions(opts ChatOptions) map[string]any {
 		m["top_p"] = opts.TopP
 	}
 
+	if opts.MaxTokens > 0 {
+		m["num_predict"] = opts.MaxTokens
+	}
+
+	if opts.Seed != nil {
+		m["seed"] = *opts.Seed
+	}
+
+	if stop := opts.stop(); len(stop) > 0 {
+		m["stop"] = stop
+	}
+
 	for k, v := range opts.Options {
 		m[k] = v
 	}
 	return m
 }
 
+// ollamaThink maps an explicit low/medium/high effort to Ollama's string think levels (gpt-oss)
+// and everything else to the boolean toggle other thinking models expect.
+func ollamaThink(opts ChatOptions) *api.ThinkValue {
+	switch effort := strings.ToLower(strings.TrimSpace(opts.ReasoningEffort)); effort {
+	case "low", "medium", "high":
+		return &api.ThinkValue{Value: effort}
+	}
+	return &api.ThinkValue{Value: opts.thinkingEnabled()}
+}
+
 // ensureFormat returns the provided format or a default JSON indicator.
 func ensureFormat(f json.RawMessage) json.RawMessage {
 	if len(f) == 0 {
@@ -90,41 +236,93 @@ func onDeltaOrNoop(fn func(content, thinking string) error) func(string, string)
 // Chat performs a chat completion against Ollama.
 // onDelta is invoked for each response chunk; in non-stream mode it may be called once with the full content.
 // The callback receives the assistant content and (optionally) the model's thinking chunk if present.
-func (o *Ollama) Chat(ctx context.Context, opts ChatOptions) error {
+func (o *Ollama) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
 	if o == nil || o.client == nil {
-		return ErrNilClient
+		return Usage{}, ErrNilClient
 	}
 
-	messages := make([]api.Message, 0, MaxMessageRoleCount)
+	turns := opts.turns()
+
+	messages := make([]api.Message, 0, 1+len(turns))
 	if system := strings.TrimSpace(opts.SystemPrompt); system != "" {
 		messages = append(messages, api.Message{Role: "system", Content: system})
 	}
 
-	userMsg := api.Message{Role: "user", Content: opts.Prompt}
-	if imgs := toAPIImages(opts.Images); len(imgs) > 0 {
-		userMsg.Images = imgs
-	}
+	for _, t := range turns {
+		msg := api.Message{Role: t.Role, Content: t.Content}
+		if imgs := toAPIImages(t.Images); len(imgs) > 0 {
+			msg.Images = imgs
+		}
 
-	messages = append(messages, userMsg)
+		messages = append(messages, msg)
+	}
 
-	merged := mergeOptions(opts)
+	merged := mergeOptions(opts, o.options)
 	format := ensureFormat(opts.Format)
 
 	req := &api.ChatRequest{
-		Model:    opts.Model,
-		Messages: messages,
-		Think:    &api.ThinkValue{Value: opts.Think},
-		Options:  merged,
-		Format:   format,
-		Stream:   streamPtr(opts.Stream),
+		Model:     opts.Model,
+		Messages:  messages,
+		Think:     ollamaThink(opts),
+		Options:   merged,
+		Format:    format,
+		Stream:    streamPtr(opts.Stream),
+		KeepAlive: o.keepAlive,
 	}
 
+	var usage Usage
+
+	done := false
 	respFunc := func(resp api.ChatResponse) error {
+		done = done || resp.Done
+		if resp.Done {
+			usage.PromptTokens = resp.PromptEvalCount
+			usage.CompletionTokens = resp.EvalCount
+		}
+
 		onDelta := onDeltaOrNoop(opts.OnDelta)
 		return onDelta(resp.Message.Content, resp.Message.Thinking)
 	}
-	return o.client.Chat(ctx, req, respFunc)
+
+	if err := o.client.Chat(ctx, req, respFunc); err != nil {
+		return usage, err
+	}
+
+	// The client stops silently when a stream is cut, so require the final done chunk.
+	if !done {
+		return usage, fmt.Errorf("providers/ollama: stream ended before done: %w", io.ErrUnexpectedEOF)
+	}
+	return usage.withEstimatedImageTokens("ollama", opts.allImages()), nil
 }
 
 // ErrNilClient is returned when the provider is used without a valid client.
 var ErrNilClient = errors.New("providers/ollama: nil client")
+
+// ListModels implements ModelLister using /api/tags, with vision support from /api/show.
+func (o *Ollama) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if o == nil || o.client == nil {
+		return nil, ErrNilClient
+	}
+
+	resp, err := o.client.List(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("providers/ollama: list models: %w", err)
+	}
+
+	models := make([]ModelInfo, 0, len(resp.Models))
+	for _, m := range resp.Models {
+		info := ModelInfo{ID: m.Name}
+
+		// Capabilities are only exposed per model; a failed lookup leaves vision unknown.
+		if show, err := o.client.Show(ctx, &api.ShowRequest{Model: m.Name}); err == nil && len(show.Capabilities) > 0 {
+			vision := false
+			for _, c := range show.Capabilities {
+				vision = vision || string(c) == "vision"
+			}
+			info.Vision = boolPtr(vision)
+		}
+
+		models = append(models, info)
+	}
+	return models, nil
+}
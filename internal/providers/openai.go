@@ -14,16 +14,21 @@ import (
 
 	openai "github.com/openai/openai-go/v3"
 	"github.com/openai/openai-go/v3/option"
+	"github.com/openai/openai-go/v3/packages/respjson"
 	"github.com/openai/openai-go/v3/shared"
 )
 
 // OpenAI implements the Provider interface using the official OpenAI Go SDK.
 type OpenAI struct {
-	client openai.Client
+	client     openai.Client
+	api        string // "chat" (default) or "responses"
+	deployment string // Azure deployment; replaces the requested model when set
 }
 
-const browserUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 " +
-	"(KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+const (
+	openAIAPIChat      = "chat"
+	openAIAPIResponses = "responses"
+)
 
 // NewOpenAI constructs an OpenAI provider from ProviderConfig.
 func NewOpenAI(cfg ProviderConfig) (*OpenAI, error) {
@@ -33,7 +38,9 @@ func NewOpenAI(cfg ProviderConfig) (*OpenAI, error) {
 	if strings.TrimSpace(cfg.APIKey) != "" {
 		opts = append(opts, option.WithAPIKey(cfg.APIKey))
 		// Some OpenAI-compatible gateways expect X-Api-Key instead of Authorization.
-		opts = append(opts, option.WithHeader("X-Api-Key", cfg.APIKey))
+		if !cfg.HTTP.NoAPIKeyHeader {
+			opts = append(opts, option.WithHeader("X-Api-Key", cfg.APIKey))
+		}
 	}
 
 	// BaseURL: the SDK expects a base like https://.../v1
@@ -46,111 +53,268 @@ func NewOpenAI(cfg ProviderConfig) (*OpenAI, error) {
 		opts = append(opts, option.WithBaseURL(b))
 	}
 
-	// Spoof a browser UA for compatibility with some proxies/gateways.
-	opts = append(opts, option.WithHeader("User-Agent", browserUserAgent))
+	// Spoof a browser UA for compatibility with some proxies/gateways unless overridden.
+	opts = append(opts, option.WithHeader("User-Agent", cfg.HTTP.userAgent()))
+
+	httpClient, err := cfg.HTTP.client(0)
+	if err != nil {
+		return nil, err
+	}
+	opts = append(opts, option.WithHTTPClient(httpClient))
+
+	// Retries are handled by WithRetry so they stay consistent across providers.
+	opts = append(opts, option.WithMaxRetries(0))
+
+	api, ok := openAIAPIMode(cfg.OpenAI.API)
+	if !ok {
+		return nil, fmt.Errorf("providers/openai: unsupported api %q (want chat or responses)", cfg.OpenAI.API)
+	}
 
 	c := openai.NewClient(opts...)
-	return &OpenAI{client: c}, nil
+	return &OpenAI{client: c, api: api}, nil
 }
 
-// Chat calls the Chat Completions API with optional vision inputs and streaming.
-func (o *OpenAI) Chat(ctx context.Context, opts ChatOptions) error {
-	if o == nil {
-		return fmt.Errorf("providers/openai: nil client")
+// openAIAPIMode normalizes the configured API name, defaulting to Chat Completions.
+func openAIAPIMode(s string) (string, bool) {
+	switch api := strings.ToLower(strings.TrimSpace(s)); api {
+	case "", openAIAPIChat:
+		return openAIAPIChat, true
+	case openAIAPIResponses:
+		return api, true
+	default:
+		return "", false
 	}
+}
 
-	// Build content parts: text + optional images (as data URLs)
-	parts := []openai.ChatCompletionContentPartUnionParam{
-		openai.TextContentPart(strings.TrimSpace(opts.Prompt)),
+// model returns the model name to send, which is the deployment on Azure.
+func (o *OpenAI) model(requested string) string {
+	if o.deployment != "" {
+		return o.deployment
 	}
+	return requested
+}
 
-	for _, img := range opts.Images {
-		mime := http.DetectContentType(img)
-		if !strings.HasPrefix(mime, "image/") {
-			mime = "image/png"
-		}
+// openAIReasoningEffort returns the reasoning effort to send and whether it turns reasoning
+// on. Think without an effort sends "medium"; neither sends nothing, as models without
+// reasoning reject the parameter. Reasoning models in turn reject temperature and top_p, so
+// those are left out while reasoning is on.
+func openAIReasoningEffort(opts ChatOptions) (string, bool) {
+	return opts.reasoningEffort(), opts.thinkingEnabled()
+}
 
-		dataURL := "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(img)
-		parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{URL: dataURL}))
+// Chat calls the Chat Completions API (or the Responses API when so configured) with optional
+// vision inputs and streaming.
+func (o *OpenAI) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	if o == nil {
+		return Usage{}, fmt.Errorf("providers/openai: nil client")
 	}
 
-	messages := make([]openai.ChatCompletionMessageParamUnion, 0, MaxMessageRoleCount)
-	if system := strings.TrimSpace(opts.SystemPrompt); system != "" {
-		messages = append(messages, openai.ChatCompletionMessageParamUnion{
-			OfSystem: &openai.ChatCompletionSystemMessageParam{
-				Content: openai.ChatCompletionSystemMessageParamContentUnion{
-					OfString: openai.String(system),
-				},
-			},
-		})
+	if o.api == openAIAPIResponses {
+		return o.chatResponses(ctx, opts)
 	}
 
-	messages = append(messages, openai.ChatCompletionMessageParamUnion{
-		OfUser: &openai.ChatCompletionUserMessageParam{
-			Content: openai.ChatCompletionUserMessageParamContentUnion{
-				OfArrayOfContentParts: parts,
-			},
-		},
-	})
-
 	params := openai.ChatCompletionNewParams{
-		Messages:    messages,
-		Model:       shared.ChatModel(opts.Model),
-		Temperature: openai.Float(opts.Temperature),
-		TopP:        openai.Float(opts.TopP),
+		Messages: openAIMessages(opts),
+		Model:    shared.ChatModel(o.model(opts.Model)),
 	}
 
 	if !opts.NoResponseFormat {
 		params.ResponseFormat = toResponseFormat(opts.Format)
 	}
 
+	effort, reasoning := openAIReasoningEffort(opts)
+	if effort != "" {
+		params.ReasoningEffort = shared.ReasoningEffort(effort)
+	}
+
+	if !reasoning {
+		params.Temperature = openai.Float(opts.Temperature)
+		params.TopP = openai.Float(opts.TopP)
+	}
+
+	if opts.MaxTokens > 0 {
+		params.MaxCompletionTokens = openai.Int(int64(opts.MaxTokens))
+	}
+
+	if opts.Seed != nil {
+		params.Seed = openai.Int(*opts.Seed)
+	}
+
+	if stop := opts.stop(); len(stop) > 0 {
+		params.Stop = openai.ChatCompletionNewParamsStopUnion{OfStringArray: stop}
+	}
+
 	onDelta := onDeltaOrNoop(opts.OnDelta)
 
 	if opts.Stream {
-		return o.handleStreamingChat(ctx, params, onDelta)
+		params.StreamOptions = openai.ChatCompletionStreamOptionsParam{IncludeUsage: openai.Bool(true)}
+
+		usage, err := o.handleStreamingChat(ctx, params, onDelta)
+		return usage.withEstimatedImageTokens("openai", opts.allImages()), err
 	}
 
 	resp, err := o.client.Chat.Completions.New(ctx, params)
 	if err != nil {
-		return formatOpenAIAPIError("providers/openai: chat completion failed", err)
+		return Usage{}, formatOpenAIAPIError("providers/openai: chat completion failed", err)
 	}
 
+	usage := openAIUsage(resp.Usage).withEstimatedImageTokens("openai", opts.allImages())
 	if len(resp.Choices) == 0 {
-		return fmt.Errorf("providers/openai: empty choices")
+		return usage, fmt.Errorf("providers/openai: empty choices")
+	}
+
+	msg := resp.Choices[0].Message
+	if reasoning := reasoningContent(msg.JSON.ExtraFields); reasoning != "" {
+		if err := onDelta("", reasoning); err != nil {
+			return usage, err
+		}
 	}
-	return onDelta(strings.TrimSpace(resp.Choices[0].Message.Content), "")
+	return usage, onDelta(strings.TrimSpace(msg.Content), "")
 }
 
 func (o *OpenAI) handleStreamingChat(
 	ctx context.Context,
 	params openai.ChatCompletionNewParams,
 	onDelta func(string, string) error,
-) error {
+) (Usage, error) {
 	stream := o.client.Chat.Completions.NewStreaming(ctx, params)
 	defer stream.Close()
 
+	var (
+		usage    Usage
+		finished bool
+	)
+
 	for stream.Next() {
 		ch := stream.Current()
 
+		// With include_usage the final chunk carries usage and no choices.
+		if ch.JSON.Usage.Valid() {
+			usage = openAIUsage(ch.Usage)
+		}
+
 		if len(ch.Choices) == 0 {
 			continue
 		}
 
-		content := strings.TrimSpace(ch.Choices[0].Delta.Content)
+		if ch.Choices[0].FinishReason != "" {
+			finished = true
+		}
+
+		delta := ch.Choices[0].Delta
+		if reasoning := reasoningContent(delta.JSON.ExtraFields); reasoning != "" {
+			if err := onDelta("", reasoning); err != nil {
+				return usage, err
+			}
+		}
+
+		content := strings.TrimSpace(delta.Content)
 		if content == "" {
 			continue
 		}
 
 		if err := onDelta(content, ""); err != nil {
-			return err
+			return usage, err
 		}
 	}
 
 	if err := stream.Err(); err != nil {
-		return formatOpenAIAPIError("providers/openai: streaming chat completion failed", err)
+		return usage, formatOpenAIAPIError("providers/openai: streaming chat completion failed", err)
 	}
 
-	return nil
+	// The SDK ends a cut stream like a complete one, so require a finish_reason.
+	if !finished {
+		return usage, fmt.Errorf("providers/openai: stream ended before finish_reason: %w", io.ErrUnexpectedEOF)
+	}
+	return usage, nil
+}
+
+// openAIMessages converts the system prompt and conversation turns into Chat Completions messages.
+func openAIMessages(opts ChatOptions) []openai.ChatCompletionMessageParamUnion {
+	turns := opts.turns()
+
+	messages := make([]openai.ChatCompletionMessageParamUnion, 0, 1+len(turns))
+	if system := strings.TrimSpace(opts.SystemPrompt); system != "" {
+		messages = append(messages, openai.ChatCompletionMessageParamUnion{
+			OfSystem: &openai.ChatCompletionSystemMessageParam{
+				Content: openai.ChatCompletionSystemMessageParamContentUnion{
+					OfString: openai.String(system),
+				},
+			},
+		})
+	}
+
+	for _, t := range turns {
+		if t.Role == RoleAssistant {
+			messages = append(messages, openai.ChatCompletionMessageParamUnion{
+				OfAssistant: &openai.ChatCompletionAssistantMessageParam{
+					Content: openai.ChatCompletionAssistantMessageParamContentUnion{
+						OfString: openai.String(t.Content),
+					},
+				},
+			})
+
+			continue
+		}
+
+		// Build content parts: text + optional images (as data URLs)
+		parts := []openai.ChatCompletionContentPartUnionParam{
+			openai.TextContentPart(strings.TrimSpace(t.Content)),
+		}
+
+		for _, img := range t.Images {
+			parts = append(parts, openai.ImageContentPart(openai.ChatCompletionContentPartImageImageURLParam{
+				URL:    imageDataURL(img),
+				Detail: opts.imageDetail(),
+			}))
+		}
+
+		messages = append(messages, openai.ChatCompletionMessageParamUnion{
+			OfUser: &openai.ChatCompletionUserMessageParam{
+				Content: openai.ChatCompletionUserMessageParamContentUnion{
+					OfArrayOfContentParts: parts,
+				},
+			},
+		})
+	}
+	return messages
+}
+
+// imageDataURL encodes an image as a base64 data URL, sniffing the MIME type.
+func imageDataURL(img []byte) string {
+	mime := http.DetectContentType(img)
+	if !strings.HasPrefix(mime, "image/") {
+		mime = "image/png"
+	}
+	return "data:" + mime + ";base64," + base64.StdEncoding.EncodeToString(img)
+}
+
+// openAIUsage converts SDK usage, splitting reasoning tokens out of the completion count.
+func openAIUsage(u openai.CompletionUsage) Usage {
+	reasoning := int(u.CompletionTokensDetails.ReasoningTokens)
+
+	return Usage{
+		PromptTokens:     int(u.PromptTokens),
+		CompletionTokens: int(u.CompletionTokens) - reasoning,
+		ThinkingTokens:   reasoning,
+	}
+}
+
+// reasoningContent extracts the non-standard reasoning text that OpenAI-compatible servers
+// (DeepSeek, vLLM, Qwen gateways) attach to messages and deltas as reasoning_content or reasoning.
+func reasoningContent(extra map[string]respjson.Field) string {
+	for _, key := range []string{"reasoning_content", "reasoning"} {
+		f, ok := extra[key]
+		if !ok || !f.Valid() {
+			continue
+		}
+
+		var s string
+		if json.Unmarshal([]byte(f.Raw()), &s) == nil && s != "" {
+			return s
+		}
+	}
+	return ""
 }
 
 // toResponseFormat converts a raw Format value into an OpenAI ResponseFormat union.
@@ -339,3 +503,23 @@ func readResponseBody(body io.ReadCloser) (string, error) {
 
 	return string(bodyBytes), nil
 }
+
+// ListModels implements ModelLister using /v1/models. The API does not report modalities.
+// Azure returns ErrModelListUnsupported.
+func (o *OpenAI) ListModels(ctx context.Context) ([]ModelInfo, error) {
+	if o.deployment != "" {
+		return nil, fmt.Errorf("providers/azure: %w; deployments are managed in Azure", ErrModelListUnsupported)
+	}
+
+	iter := o.client.Models.ListAutoPaging(ctx)
+
+	var models []ModelInfo
+	for iter.Next() {
+		models = append(models, ModelInfo{ID: iter.Current().ID})
+	}
+
+	if err := iter.Err(); err != nil {
+		return nil, formatOpenAIAPIError("providers/openai: list models failed", err)
+	}
+	return models, nil
+}
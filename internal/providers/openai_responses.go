@@ -0,0 +1,196 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	openai "github.com/openai/openai-go/v3"
+	"github.com/openai/openai-go/v3/responses"
+	"github.com/openai/openai-go/v3/shared"
+)
+
+// chatResponses calls the Responses API (/v1/responses) with input_image parts and
+// text.format structured outputs.
+func (o *OpenAI) chatResponses(ctx context.Context, opts ChatOptions) (Usage, error) {
+	params := responses.ResponseNewParams{
+		Input: responses.ResponseNewParamsInputUnion{OfInputItemList: responsesInput(opts)},
+		Model: shared.ResponsesModel(o.model(opts.Model)),
+	}
+
+	if system := strings.TrimSpace(opts.SystemPrompt); system != "" {
+		params.Instructions = openai.String(system)
+	}
+
+	// The Responses API has no seed or stop parameters.
+	if opts.MaxTokens > 0 {
+		params.MaxOutputTokens = openai.Int(int64(opts.MaxTokens))
+	}
+
+	if !opts.NoResponseFormat {
+		params.Text = responses.ResponseTextConfigParam{Format: toResponseTextFormat(opts.Format)}
+	}
+
+	effort, reasoning := openAIReasoningEffort(opts)
+	if effort != "" {
+		params.Reasoning = shared.ReasoningParam{Effort: shared.ReasoningEffort(effort)}
+		// Reasoning text is only returned as a summary, and only when asked for.
+		if reasoning {
+			params.Reasoning.Summary = shared.ReasoningSummaryAuto
+		}
+	}
+
+	if !reasoning {
+		params.Temperature = openai.Float(opts.Temperature)
+		params.TopP = openai.Float(opts.TopP)
+	}
+
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+
+	if opts.Stream {
+		usage, err := o.handleStreamingResponses(ctx, params, onDelta)
+		return usage.withEstimatedImageTokens("openai", opts.allImages()), err
+	}
+
+	resp, err := o.client.Responses.New(ctx, params)
+	if err != nil {
+		return Usage{}, formatOpenAIAPIError("providers/openai: responses request failed", err)
+	}
+
+	usage := responsesUsage(resp.Usage).withEstimatedImageTokens("openai", opts.allImages())
+	if resp.Status == responses.ResponseStatusFailed {
+		return usage, fmt.Errorf("providers/openai: response failed: %s: %s", resp.Error.Code, resp.Error.Message)
+	}
+
+	for _, item := range resp.Output {
+		if item.Type != "reasoning" {
+			continue
+		}
+
+		for _, s := range item.Summary {
+			if err := onDelta("", s.Text); err != nil {
+				return usage, err
+			}
+		}
+	}
+	return usage, onDelta(strings.TrimSpace(resp.OutputText()), "")
+}
+
+func (o *OpenAI) handleStreamingResponses(
+	ctx context.Context,
+	params responses.ResponseNewParams,
+	onDelta func(string, string) error,
+) (Usage, error) {
+	stream := o.client.Responses.NewStreaming(ctx, params)
+	defer stream.Close()
+
+	var (
+		usage Usage
+		done  bool
+	)
+
+	for stream.Next() {
+		ev := stream.Current()
+
+		switch ev.Type {
+		case "response.output_text.delta":
+			if ev.Delta == "" {
+				continue
+			}
+
+			if err := onDelta(ev.Delta, ""); err != nil {
+				return usage, err
+			}
+		case "response.reasoning_summary_text.delta", "response.reasoning_text.delta":
+			if err := onDelta("", ev.Delta); err != nil {
+				return usage, err
+			}
+		case "response.completed", "response.incomplete":
+			usage = responsesUsage(ev.Response.Usage)
+			done = true
+		case "response.failed":
+			usage = responsesUsage(ev.Response.Usage)
+			return usage, fmt.Errorf("providers/openai: response failed: %s: %s",
+				ev.Response.Error.Code, ev.Response.Error.Message)
+		case "error":
+			return usage, fmt.Errorf("providers/openai: stream error: %s: %s", ev.Code, ev.Message)
+		}
+	}
+
+	if err := stream.Err(); err != nil {
+		return usage, formatOpenAIAPIError("providers/openai: streaming responses request failed", err)
+	}
+
+	if !done {
+		return usage, fmt.Errorf("providers/openai: response stream ended before completion: %w", io.ErrUnexpectedEOF)
+	}
+	return usage, nil
+}
+
+// responsesInput converts conversation turns into Responses API input messages.
+func responsesInput(opts ChatOptions) responses.ResponseInputParam {
+	turns := opts.turns()
+	input := make(responses.ResponseInputParam, 0, len(turns))
+
+	for _, t := range turns {
+		if t.Role == RoleAssistant {
+			input = append(input, responses.ResponseInputItemParamOfMessage(t.Content, responses.EasyInputMessageRoleAssistant))
+			continue
+		}
+
+		content := responses.ResponseInputMessageContentListParam{
+			{OfInputText: &responses.ResponseInputTextParam{Text: strings.TrimSpace(t.Content)}},
+		}
+
+		for _, img := range t.Images {
+			content = append(content, responses.ResponseInputContentUnionParam{
+				OfInputImage: &responses.ResponseInputImageParam{
+					Detail:   responses.ResponseInputImageDetail(opts.imageDetail()),
+					ImageURL: openai.String(imageDataURL(img)),
+				},
+			})
+		}
+
+		input = append(input, responses.ResponseInputItemParamOfMessage(content, responses.EasyInputMessageRoleUser))
+	}
+	return input
+}
+
+// responsesUsage converts Responses API usage, splitting reasoning tokens out of the output count.
+func responsesUsage(u responses.ResponseUsage) Usage {
+	reasoning := int(u.OutputTokensDetails.ReasoningTokens)
+
+	return Usage{
+		PromptTokens:     int(u.InputTokens),
+		CompletionTokens: int(u.OutputTokens) - reasoning,
+		ThinkingTokens:   reasoning,
+	}
+}
+
+// toResponseTextFormat converts a raw Format value into a Responses API text.format union.
+// Only JSON objects can be sent as schemas; anything else falls back to JSON object mode.
+func toResponseTextFormat(raw json.RawMessage) responses.ResponseFormatTextConfigUnionParam {
+	jsonObject := responses.ResponseFormatTextConfigUnionParam{
+		OfJSONObject: &shared.ResponseFormatJSONObjectParam{},
+	}
+
+	trim := strings.TrimSpace(string(raw))
+	if trim == "" || trim == `"json"` || trim == `json` {
+		return jsonObject
+	}
+
+	var schema map[string]any
+	if json.Unmarshal(raw, &schema) != nil || schema == nil {
+		return jsonObject
+	}
+
+	return responses.ResponseFormatTextConfigUnionParam{
+		OfJSONSchema: &responses.ResponseFormatTextJSONSchemaConfigParam{
+			Name:   "dino_schema",
+			Strict: openai.Bool(true),
+			Schema: schema,
+		},
+	}
+}
@@ -1,11 +1,31 @@
 package providers
 
-import "encoding/json"
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+const (
+	thinkingBudgetLow    = 1024
+	thinkingBudgetMedium = 8192
+	thinkingBudgetHigh   = 24576
+)
 
+// Conversation roles accepted in Message.Role.
 const (
-	MaxMessageRoleCount = 2
+	RoleUser      = "user"
+	RoleAssistant = "assistant"
 )
 
+// Message is one earlier turn of a conversation, such as an in-context example, a previous
+// answer or a correction request. Images are only sent on user turns.
+type Message struct {
+	Role    string // RoleUser or RoleAssistant ("model" is accepted as an alias)
+	Content string
+	Images  [][]byte
+}
+
 // ChatOptions describes parameters for a provider chat call.
 // This is designed to be generic enough so other providers can adopt a similar shape.
 type ChatOptions struct {
@@ -21,18 +41,49 @@ type ChatOptions struct {
 	Temperature float64
 	TopP        float64
 
+	// ReasoningEffort ("none", "minimal", "low", "medium", "high") tunes reasoning models; a
+	// value other than "none" implies Think. ThinkingBudget caps reasoning tokens where the
+	// provider takes a budget instead (Gemini, Anthropic); 0 derives it from the effort.
+	ReasoningEffort string
+	ThinkingBudget  int
+
+	// MaxTokens caps generated tokens; 0 uses the provider default. Seed requests reproducible
+	// sampling where supported (OpenAI chat, Gemini, Ollama); nil leaves it to the provider.
+	// Stop lists sequences that end generation (not supported by the OpenAI Responses API).
+	MaxTokens int
+	Seed      *int64
+	Stop      []string
+
 	// Optional JSON schema/format control. If nil, defaults to "\"json\"".
 	Format           json.RawMessage
 	NoResponseFormat bool
 
 	// Optional vision inputs. If provided, they will be attached to the user message.
 	Images [][]byte
+	// ImageDetail is the OpenAI image detail level: "auto" (default), "low" or "high".
+	ImageDetail string
+	// ImageNames optionally holds the names of Images, in the same order: slash-separated
+	// paths relative to the input folder. The mock provider matches fixtures by them and the
+	// exec provider passes them on; the others don't send them.
+	ImageNames []string
+
+	// Messages holds earlier turns sent before the current Prompt and Images, which form the
+	// final user turn. Leave Prompt and Images empty to send Messages as the whole conversation.
+	Messages []Message
 
 	// Extra provider-specific options; values here override the derived ones.
 	Options map[string]any
 
 	// OnDelta callback for streaming/non-streaming responses; if nil, chunks are ignored.
 	OnDelta func(content, thinking string) error
+
+	// OnRetry is called by WithRetry before a failed attempt is repeated; content already
+	// delivered through OnDelta for that attempt should be discarded.
+	OnRetry func(attempt int, err error, wait time.Duration)
+
+	// Cacheable, when set, is asked before WithCache stores a response; returning false keeps
+	// an answer the caller could not use out of the cache.
+	Cacheable func(content string) bool
 }
 
 // Option is a functional option to build ChatOptions ergonomically.
@@ -62,12 +113,36 @@ func WithStream(b bool) Option { return func(c *ChatOptions) { c.Stream = b } }
 // WithThink toggles reasoning/thinking capability if supported.
 func WithThink(b bool) Option { return func(c *ChatOptions) { c.Think = b } }
 
+// WithReasoningEffort sets the reasoning effort for models that support it.
+func WithReasoningEffort(s string) Option { return func(c *ChatOptions) { c.ReasoningEffort = s } }
+
+// WithThinkingBudget caps reasoning tokens for providers that take a budget.
+func WithThinkingBudget(n int) Option { return func(c *ChatOptions) { c.ThinkingBudget = n } }
+
+// WithMaxTokens caps the number of generated tokens.
+func WithMaxTokens(n int) Option { return func(c *ChatOptions) { c.MaxTokens = n } }
+
+// WithSeed requests reproducible sampling with the given seed.
+func WithSeed(seed int64) Option { return func(c *ChatOptions) { c.Seed = &seed } }
+
+// WithStop sets sequences that end generation.
+func WithStop(seqs ...string) Option { return func(c *ChatOptions) { c.Stop = seqs } }
+
+// WithImageDetail sets the OpenAI image detail level ("auto", "low" or "high").
+func WithImageDetail(s string) Option { return func(c *ChatOptions) { c.ImageDetail = s } }
+
 // WithSystemPrompt injects a system message ahead of the user prompt.
 func WithSystemPrompt(s string) Option { return func(c *ChatOptions) { c.SystemPrompt = s } }
 
 // WithImages attaches one or more image bytes for multimodal input.
 func WithImages(imgs ...[]byte) Option { return func(c *ChatOptions) { c.Images = imgs } }
 
+// WithImageNames records the names of the attached images, relative to the input folder.
+func WithImageNames(names ...string) Option { return func(c *ChatOptions) { c.ImageNames = names } }
+
+// WithMessages prepends conversation history to the current prompt.
+func WithMessages(msgs ...Message) Option { return func(c *ChatOptions) { c.Messages = msgs } }
+
 // WithFormat sets the output format/schema raw message.
 func WithFormat(raw json.RawMessage) Option { return func(c *ChatOptions) { c.Format = raw } }
 
@@ -109,3 +184,101 @@ func WithExtraOption(k string, v any) Option {
 func WithOnDelta(fn func(content, thinking string) error) Option {
 	return func(c *ChatOptions) { c.OnDelta = fn }
 }
+
+// WithOnRetry sets the callback invoked before a retried attempt.
+func WithOnRetry(fn func(attempt int, err error, wait time.Duration)) Option {
+	return func(c *ChatOptions) { c.OnRetry = fn }
+}
+
+// WithCacheable sets the check a response must pass before WithCache stores it.
+func WithCacheable(fn func(content string) bool) Option {
+	return func(c *ChatOptions) { c.Cacheable = fn }
+}
+
+// reasoningEffort returns the normalized effort, defaulting to "medium" when only Think is set.
+func (c ChatOptions) reasoningEffort() string {
+	effort := strings.ToLower(strings.TrimSpace(c.ReasoningEffort))
+	if effort == "" && c.Think {
+		return "medium"
+	}
+	return effort
+}
+
+// thinkingEnabled reports whether reasoning was requested via Think or ReasoningEffort.
+func (c ChatOptions) thinkingEnabled() bool {
+	effort := c.reasoningEffort()
+	return effort != "" && effort != "none"
+}
+
+// thinkingBudget returns the explicit budget or one derived from the effort; 0 means no thinking.
+func (c ChatOptions) thinkingBudget() int {
+	if !c.thinkingEnabled() {
+		return 0
+	}
+
+	if c.ThinkingBudget > 0 {
+		return c.ThinkingBudget
+	}
+
+	switch c.reasoningEffort() {
+	case "minimal", "low":
+		return thinkingBudgetLow
+	case "high", "xhigh":
+		return thinkingBudgetHigh
+	default:
+		return thinkingBudgetMedium
+	}
+}
+
+// imageDetail returns the normalized image detail level, defaulting to "auto".
+func (c ChatOptions) imageDetail() string {
+	if d := strings.ToLower(strings.TrimSpace(c.ImageDetail)); d != "" {
+		return d
+	}
+	return "auto"
+}
+
+// stop returns the non-empty stop sequences.
+func (c ChatOptions) stop() []string {
+	var out []string
+
+	for _, s := range c.Stop {
+		if s != "" {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+// turns returns Messages followed by Prompt and Images as the final user turn, with roles
+// normalized to RoleUser or RoleAssistant and images dropped from assistant turns.
+func (c ChatOptions) turns() []Message {
+	out := make([]Message, 0, len(c.Messages)+1)
+
+	for _, m := range c.Messages {
+		switch strings.ToLower(strings.TrimSpace(m.Role)) {
+		case RoleAssistant, "model":
+			out = append(out, Message{Role: RoleAssistant, Content: m.Content})
+		default:
+			out = append(out, Message{Role: RoleUser, Content: m.Content, Images: m.Images})
+		}
+	}
+
+	if strings.TrimSpace(c.Prompt) != "" || len(c.Images) > 0 {
+		out = append(out, Message{Role: RoleUser, Content: c.Prompt, Images: c.Images})
+	}
+	return out
+}
+
+// allImages returns the images of every user turn, for token estimates.
+func (c ChatOptions) allImages() [][]byte {
+	if len(c.Messages) == 0 {
+		return c.Images
+	}
+
+	var imgs [][]byte
+	for _, m := range c.turns() {
+		imgs = append(imgs, m.Images...)
+	}
+	return imgs
+}
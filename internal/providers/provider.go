@@ -8,29 +8,108 @@ import (
 
 // Provider defines the common interface all model providers should implement.
 // Chat should call onDelta for each content chunk (and optionally thinking chunks).
-// Implementations may call onDelta once when not streaming.
+// Implementations may call onDelta once when not streaming. The returned Usage holds
+// whatever token counts the provider reported; it may be partial or zero.
 type Provider interface {
-	Chat(ctx context.Context, opts ChatOptions) error
+	Chat(ctx context.Context, opts ChatOptions) (Usage, error)
 }
 
 // ProviderConfig contains common configuration for all providers.
 type ProviderConfig struct {
 	APIKey   string
 	BaseURL  string
-	AuthType string // "api_key" (default) or "auth_token"
+	AuthType string // "api_key" or "auth_token"; the default is "api_key", except for ollama
+
+	// HTTP customizes the client: timeout, proxy, CA bundle, headers and User-Agent.
+	HTTP HTTPConfig
+
+	// Ollama holds settings only used by the ollama provider.
+	Ollama OllamaConfig
+	// OpenAI holds settings used by the openai and azure providers.
+	OpenAI OpenAIConfig
+	// Azure holds settings only used by the azure provider.
+	Azure AzureConfig
+	// Gemini holds settings only used by the gemini provider.
+	Gemini GeminiConfig
+	// Mock holds settings only used by the mock provider.
+	Mock MockConfig
+	// Exec holds settings only used by the exec provider.
+	Exec ExecConfig
+}
+
+// OpenAIConfig contains OpenAI-specific request settings.
+type OpenAIConfig struct {
+	API string // "chat" (default, /v1/chat/completions) or "responses" (/v1/responses)
+}
+
+// OllamaConfig contains Ollama-specific request settings.
+type OllamaConfig struct {
+	KeepAlive  string // duration ("5m") or seconds ("-1" keeps the model loaded)
+	NumCtx     int    // context window size (num_ctx); 0 uses the model default
+	NumPredict int    // max tokens to generate (num_predict); 0 uses the model default
+}
+
+// options returns the non-zero knobs as Ollama request options.
+func (c OllamaConfig) options() map[string]any {
+	m := map[string]any{}
+	if c.NumCtx > 0 {
+		m["num_ctx"] = c.NumCtx
+	}
+
+	if c.NumPredict != 0 {
+		m["num_predict"] = c.NumPredict
+	}
+	return m
+}
+
+// AzureConfig identifies an Azure OpenAI deployment; BaseURL is the resource endpoint.
+type AzureConfig struct {
+	Deployment string // deployment name; defaults to the model name
+	APIVersion string // api-version query parameter; defaults to a GA version, or a preview one for Responses
+}
+
+// GeminiConfig contains Gemini-specific request settings.
+type GeminiConfig struct {
+	SafetySettings  []GeminiSafetySetting // sent as safetySettings; empty uses the API defaults
+	UploadThreshold int64                 // images above this many bytes use the Files API; 0 = 10 MiB, <0 never
 }
 
+// GeminiSafetySetting overrides the block threshold for one harm category, e.g.
+// HARM_CATEGORY_DANGEROUS_CONTENT with BLOCK_ONLY_HIGH.
+type GeminiSafetySetting struct {
+	Category  string `json:"category"`
+	Threshold string `json:"threshold"`
+}
+
+// Route is one provider/model entry of an ordered fallback chain.
+type Route struct {
+	Name     string // provider name, e.g. "gemini"
+	Model    string
+	Provider Provider
+}
+
+// String returns "provider/model" for logs.
+func (r Route) String() string { return r.Name + "/" + r.Model }
+
 // New returns a Provider implementation based on the given name.
-// Supports: "ollama", "openai", "gemini".
+// Supports: "ollama", "openai", "azure", "gemini", "anthropic", "mock", "exec".
 func New(name string, cfg ProviderConfig) (Provider, error) {
 	s := strings.ToLower(strings.TrimSpace(name))
 	switch s {
 	case "", "ollama":
-		return NewOllamaFromEnv()
+		return NewOllamaFromConfig(cfg)
 	case "openai":
 		return NewOpenAI(cfg)
+	case "azure":
+		return NewAzureOpenAI(cfg)
 	case "gemini":
 		return NewGemini(cfg)
+	case "anthropic":
+		return NewAnthropic(cfg)
+	case "mock":
+		return NewMock(cfg)
+	case "exec":
+		return NewExec(cfg)
 	default:
 		return nil, fmt.Errorf("unsupported provider: %s", name)
 	}
@@ -0,0 +1,220 @@
+package providers
+
+import (
+	"bytes"
+	"context"
+	"image"
+	_ "image/gif"  // register GIF for DecodeConfig
+	_ "image/jpeg" // register JPEG for DecodeConfig
+	_ "image/png"  // register PNG for DecodeConfig
+	"math"
+	"strings"
+	"sync"
+	"time"
+
+	_ "golang.org/x/image/webp" // register WebP for DecodeConfig
+)
+
+const (
+	charsPerToken          = 4
+	fallbackImageTokens    = 1000
+	openAIImageBaseTokens  = 85
+	openAIImageTileTokens  = 170
+	openAIImageTileSize    = 512
+	openAIImageMaxSide     = 2048
+	openAIImageShortSide   = 768
+	geminiImageTokens      = 258
+	geminiImageSmallSide   = 384
+	geminiImageTileSize    = 768
+	anthropicImageMaxSide  = 1568
+	anthropicPixelsPerTok  = 750
+	qwenPatchSize          = 28
+	qwenMaxImageTokens     = 16384
+	secondsPerMinute       = 60
+	rateLimitMinSleepSlice = 10 * time.Millisecond
+)
+
+// RateLimit describes client-side pacing for a single provider. Zero fields are unlimited.
+type RateLimit struct {
+	RPM         int // requests per minute
+	TPM         int // estimated input tokens per minute (prompt + images)
+	MaxInFlight int // concurrent requests
+}
+
+// rateLimitedProvider delays Chat calls so they stay within the configured budget.
+type rateLimitedProvider struct {
+	next     Provider
+	name     string
+	requests *tokenBucket
+	tokens   *tokenBucket
+	inFlight chan struct{}
+}
+
+// WithRateLimit wraps p so requests are paced by RPM, TPM and max in-flight limits. The token
+// cost of each request is estimated before sending from the prompt length and the image
+// dimensions, using the sizing rules of the named provider.
+func WithRateLimit(p Provider, name string, limit RateLimit) Provider {
+	if p == nil || (limit.RPM <= 0 && limit.TPM <= 0 && limit.MaxInFlight <= 0) {
+		return p
+	}
+
+	rl := &rateLimitedProvider{next: p, name: strings.ToLower(strings.TrimSpace(name))}
+	if limit.RPM > 0 {
+		rl.requests = newTokenBucket(limit.RPM)
+	}
+
+	if limit.TPM > 0 {
+		rl.tokens = newTokenBucket(limit.TPM)
+	}
+
+	if limit.MaxInFlight > 0 {
+		rl.inFlight = make(chan struct{}, limit.MaxInFlight)
+	}
+	return rl
+}
+
+// Chat implements Provider.
+func (r *rateLimitedProvider) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	if r.inFlight != nil {
+		select {
+		case r.inFlight <- struct{}{}:
+			defer func() { <-r.inFlight }()
+		case <-ctx.Done():
+			return Usage{}, ctx.Err()
+		}
+	}
+
+	if r.requests != nil {
+		if err := r.requests.wait(ctx, 1); err != nil {
+			return Usage{}, err
+		}
+	}
+
+	if r.tokens != nil {
+		if err := r.tokens.wait(ctx, float64(EstimateRequestTokens(r.name, opts))); err != nil {
+			return Usage{}, err
+		}
+	}
+	return r.next.Chat(ctx, opts)
+}
+
+// tokenBucket refills continuously at perMinute/60 units per second up to perMinute.
+type tokenBucket struct {
+	mu        sync.Mutex
+	capacity  float64
+	available float64
+	perSecond float64
+	last      time.Time
+}
+
+func newTokenBucket(perMinute int) *tokenBucket {
+	c := float64(perMinute)
+
+	return &tokenBucket{
+		capacity:  c,
+		available: c,
+		perSecond: c / secondsPerMinute,
+		last:      time.Now(),
+	}
+}
+
+// wait blocks until n units are available and takes them. Requests larger than the whole
+// bucket are clamped so they wait for a full minute's budget instead of blocking forever.
+func (b *tokenBucket) wait(ctx context.Context, n float64) error {
+	n = min(n, b.capacity)
+
+	for {
+		b.mu.Lock()
+
+		now := time.Now()
+		b.available = min(b.capacity, b.available+now.Sub(b.last).Seconds()*b.perSecond)
+		b.last = now
+
+		if b.available >= n {
+			b.available -= n
+			b.mu.Unlock()
+
+			return nil
+		}
+
+		delay := time.Duration((n - b.available) / b.perSecond * float64(time.Second))
+		b.mu.Unlock()
+
+		timer := time.NewTimer(max(delay, rateLimitMinSleepSlice))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// EstimateRequestTokens approximates the input tokens of a request: roughly four characters
+// per text token plus the per-image cost for the given provider.
+func EstimateRequestTokens(provider string, opts ChatOptions) int {
+	chars := len(opts.Prompt) + len(opts.SystemPrompt)
+	for _, m := range opts.Messages {
+		chars += len(m.Content)
+	}
+
+	text := (chars + charsPerToken - 1) / charsPerToken
+	return text + estimateImagesTokens(provider, opts.allImages())
+}
+
+// estimateImagesTokens sums EstimateImageTokens over encoded images.
+func estimateImagesTokens(provider string, imgs [][]byte) int {
+	total := 0
+
+	for _, img := range imgs {
+		cfg, _, err := image.DecodeConfig(bytes.NewReader(img))
+		if err != nil {
+			total += fallbackImageTokens
+			continue
+		}
+
+		total += EstimateImageTokens(provider, cfg.Width, cfg.Height)
+	}
+	return total
+}
+
+// EstimateImageTokens approximates how many tokens an image of width x height costs on the
+// given provider, following each vendor's published resizing and tiling rules.
+func EstimateImageTokens(provider string, width, height int) int {
+	if width <= 0 || height <= 0 {
+		return fallbackImageTokens
+	}
+
+	w, h := float64(width), float64(height)
+
+	switch strings.ToLower(strings.TrimSpace(provider)) {
+	case "openai", "azure":
+		// Fit within 2048x2048, then shrink so the short side is at most 768, and count 512px tiles.
+		if s := openAIImageMaxSide / math.Max(w, h); s < 1 {
+			w, h = w*s, h*s
+		}
+
+		if s := openAIImageShortSide / math.Min(w, h); s < 1 {
+			w, h = w*s, h*s
+		}
+
+		tiles := math.Ceil(w/openAIImageTileSize) * math.Ceil(h/openAIImageTileSize)
+		return openAIImageBaseTokens + openAIImageTileTokens*int(tiles)
+	case "gemini":
+		if w <= geminiImageSmallSide && h <= geminiImageSmallSide {
+			return geminiImageTokens
+		}
+
+		tiles := math.Ceil(w/geminiImageTileSize) * math.Ceil(h/geminiImageTileSize)
+		return geminiImageTokens * int(tiles)
+	case "anthropic":
+		if s := anthropicImageMaxSide / math.Max(w, h); s < 1 {
+			w, h = w*s, h*s
+		}
+		return int(math.Ceil(w * h / anthropicPixelsPerTok))
+	default:
+		// Qwen-VL style encoders (the usual Ollama vision models) use one token per 28x28 patch.
+		patches := math.Ceil(w/qwenPatchSize) * math.Ceil(h/qwenPatchSize)
+		return int(math.Min(patches, qwenMaxImageTokens))
+	}
+}
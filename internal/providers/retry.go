@@ -0,0 +1,264 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/ollama/ollama/api"
+	openai "github.com/openai/openai-go/v3"
+)
+
+const (
+	defaultRetryAttempts       = 3
+	defaultRetryInitialBackoff = time.Second
+	defaultRetryMaxBackoff     = 30 * time.Second
+	maxRetryAfter              = 5 * time.Minute
+	retryBackoffMultiplier     = 2
+)
+
+// RetryPolicy controls how WithRetry re-attempts failed Chat calls.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of attempts including the first; 0 uses the default (3)
+	// and 1 disables retries.
+	MaxAttempts int
+	// InitialBackoff is the base delay before the second attempt; it doubles on each retry.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the computed delay. A server-provided Retry-After may exceed it.
+	MaxBackoff time.Duration
+}
+
+func (p RetryPolicy) normalized() RetryPolicy {
+	if p.MaxAttempts <= 0 {
+		p.MaxAttempts = defaultRetryAttempts
+	}
+
+	if p.InitialBackoff <= 0 {
+		p.InitialBackoff = defaultRetryInitialBackoff
+	}
+
+	if p.MaxBackoff <= 0 {
+		p.MaxBackoff = defaultRetryMaxBackoff
+	}
+
+	if p.MaxBackoff < p.InitialBackoff {
+		p.MaxBackoff = p.InitialBackoff
+	}
+	return p
+}
+
+// retryProvider wraps a Provider and re-attempts transient failures.
+type retryProvider struct {
+	next   Provider
+	policy RetryPolicy
+}
+
+// WithRetry wraps p so that rate-limit (429), server (5xx) and connection errors, including
+// streams cut mid-response, are retried with jittered exponential backoff. Before each retry
+// ChatOptions.OnRetry is invoked so callers can discard partially streamed content.
+// Errors returned by OnDelta are never retried.
+func WithRetry(p Provider, policy RetryPolicy) Provider {
+	policy = policy.normalized()
+	if p == nil || policy.MaxAttempts <= 1 {
+		return p
+	}
+	return &retryProvider{next: p, policy: policy}
+}
+
+// Chat implements Provider. The returned usage adds up all attempts, as failed ones that
+// got partway are billed too.
+func (r *retryProvider) Chat(ctx context.Context, opts ChatOptions) (Usage, error) {
+	onDelta := onDeltaOrNoop(opts.OnDelta)
+
+	var cbErr error
+
+	attemptOpts := opts
+	attemptOpts.OnDelta = func(content, thinking string) error {
+		if err := onDelta(content, thinking); err != nil {
+			cbErr = err
+			return err
+		}
+		return nil
+	}
+
+	var total Usage
+
+	for attempt := 1; ; attempt++ {
+		cbErr = nil
+
+		usage, err := r.next.Chat(ctx, attemptOpts)
+		if err == nil || cbErr != nil || attempt >= r.policy.MaxAttempts || ctx.Err() != nil {
+			total.Add(usage)
+			total.Cached = usage.Cached
+
+			return total, err
+		}
+
+		// Failed attempts only count what the provider reported; the image estimate added to
+		// every usage would otherwise bill requests that were rejected outright.
+		if usage.PromptTokens > 0 || usage.OutputTokens() > 0 {
+			total.Add(usage)
+		}
+
+		if !IsRetryable(err) {
+			return total, err
+		}
+
+		wait := r.policy.backoff(attempt, RetryAfter(err))
+		if opts.OnRetry != nil {
+			opts.OnRetry(attempt, err, wait)
+		}
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return total, err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns the delay before the attempt following the given one. A server hint wins
+// when it is longer than the computed delay.
+func (p RetryPolicy) backoff(attempt int, retryAfter time.Duration) time.Duration {
+	d := p.InitialBackoff
+	for i := 1; i < attempt && d < p.MaxBackoff; i++ {
+		d *= retryBackoffMultiplier
+	}
+
+	if d > p.MaxBackoff {
+		d = p.MaxBackoff
+	}
+
+	// Equal jitter: keep at least half the delay so retries still spread out under load.
+	half := d / 2
+	d = half + time.Duration(rand.Int64N(int64(half)+1))
+
+	if retryAfter > d {
+		d = min(retryAfter, maxRetryAfter)
+	}
+	return d
+}
+
+// IsRetryable reports whether err looks transient: HTTP 408/425/429/5xx, connection
+// failures and truncated streams. Context cancellation is never retryable.
+func IsRetryable(err error) bool {
+	if err == nil {
+		return false
+	}
+
+	kind := errorKind(err)
+	return kind == ErrRateLimited || kind == ErrTransient
+}
+
+// isTransportFailure reports connection failures and truncated streams.
+func isTransportFailure(err error) bool {
+	if errors.Is(err, io.ErrUnexpectedEOF) || errors.Is(err, io.EOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.EPIPE) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+
+	// The Ollama client reports mid-stream proxy failures as plain strings.
+	return strings.Contains(err.Error(), "connection reset by peer")
+}
+
+// StatusCode extracts the HTTP status carried by a provider error, or 0 if none is known.
+func StatusCode(err error) int {
+	var (
+		oaErr  *openai.Error
+		gErr   *geminiErrorPayload
+		aErr   *anthropicErrorPayload
+		olErr  api.StatusError
+		olAuth api.AuthorizationError
+		mErr   *mockError
+		xErr   *execError
+	)
+
+	switch {
+	case errors.As(err, &oaErr):
+		return oaErr.StatusCode
+	case errors.As(err, &gErr):
+		if gErr.HTTPStatus != 0 {
+			return gErr.HTTPStatus
+		}
+		return gErr.Code
+	case errors.As(err, &aErr):
+		return aErr.StatusCode
+	case errors.As(err, &olErr):
+		return olErr.StatusCode
+	case errors.As(err, &olAuth):
+		return olAuth.StatusCode
+	case errors.As(err, &mErr):
+		return mErr.StatusCode
+	case errors.As(err, &xErr):
+		return xErr.StatusCode
+	}
+	return 0
+}
+
+// RetryAfter returns the server-requested delay carried by a provider error, or 0.
+func RetryAfter(err error) time.Duration {
+	var (
+		oaErr *openai.Error
+		gErr  *geminiErrorPayload
+		aErr  *anthropicErrorPayload
+	)
+
+	switch {
+	case errors.As(err, &oaErr):
+		if oaErr.Response != nil {
+			return parseRetryAfter(oaErr.Response.Header)
+		}
+	case errors.As(err, &gErr):
+		return gErr.RetryAfter
+	case errors.As(err, &aErr):
+		return aErr.RetryAfter
+	}
+	return 0
+}
+
+// parseRetryAfter reads retry-after-ms (OpenAI) or Retry-After as seconds or an HTTP date.
+func parseRetryAfter(h http.Header) time.Duration {
+	if h == nil {
+		return 0
+	}
+
+	if ms := strings.TrimSpace(h.Get("Retry-After-Ms")); ms != "" {
+		if v, err := strconv.ParseFloat(ms, 64); err == nil && v > 0 {
+			return time.Duration(v * float64(time.Millisecond))
+		}
+	}
+
+	s := strings.TrimSpace(h.Get("Retry-After"))
+	if s == "" {
+		return 0
+	}
+
+	if secs, err := strconv.ParseFloat(s, 64); err == nil {
+		if secs <= 0 {
+			return 0
+		}
+		return time.Duration(secs * float64(time.Second))
+	}
+
+	if t, err := http.ParseTime(s); err == nil {
+		if d := time.Until(t); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
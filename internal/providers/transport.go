@@ -0,0 +1,105 @@
+package providers
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// browserUserAgent is sent unless HTTPConfig.UserAgent overrides it; some proxies and
+// gateways reject unfamiliar clients.
+const browserUserAgent = "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 " +
+	"(KHTML, like Gecko) Chrome/131.0.0.0 Safari/537.36"
+
+// HTTPConfig customizes the HTTP client of one provider.
+type HTTPConfig struct {
+	Timeout   time.Duration     // whole request including the streamed body; 0 = provider default, <0 none
+	Proxy     string            // proxy URL; empty honors HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+	CACert    string            // PEM file trusted in addition to the system roots
+	Headers   map[string]string // static headers added to every request, replacing defaults
+	UserAgent string            // replaces the browser User-Agent
+
+	// NoAPIKeyHeader stops the openai provider from duplicating the key into X-Api-Key.
+	NoAPIKeyHeader bool
+}
+
+// userAgent returns the configured User-Agent or the browser default.
+func (c HTTPConfig) userAgent() string {
+	if ua := strings.TrimSpace(c.UserAgent); ua != "" {
+		return ua
+	}
+	return browserUserAgent
+}
+
+// client builds an http.Client honoring the proxy, CA bundle, headers and timeout. A zero
+// Timeout uses defaultTimeout.
+func (c HTTPConfig) client(defaultTimeout time.Duration) (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if p := strings.TrimSpace(c.Proxy); p != "" {
+		u, err := url.Parse(p)
+		if err != nil || u.Host == "" {
+			return nil, fmt.Errorf("providers: invalid proxy url %q", c.Proxy)
+		}
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	if path := strings.TrimSpace(c.CACert); path != "" {
+		pool, err := caCertPool(path)
+		if err != nil {
+			return nil, err
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool, MinVersion: tls.VersionTLS12}
+	}
+
+	var rt http.RoundTripper = transport
+	if len(c.Headers) > 0 {
+		rt = &headerTransport{base: rt, headers: c.Headers}
+	}
+
+	timeout := c.Timeout
+	switch {
+	case timeout == 0:
+		timeout = defaultTimeout
+	case timeout < 0:
+		timeout = 0
+	}
+	return &http.Client{Transport: rt, Timeout: timeout}, nil
+}
+
+// caCertPool returns the system roots plus the certificates in the PEM file at path.
+func caCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("providers: read ca bundle: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("providers: no certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// headerTransport sets static headers on every request after the provider has set its own.
+type headerTransport struct {
+	base    http.RoundTripper
+	headers map[string]string
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	r := req.Clone(req.Context())
+	for k, v := range t.headers {
+		r.Header.Set(k, v)
+	}
+	return t.base.RoundTrip(r)
+}
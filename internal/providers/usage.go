@@ -0,0 +1,49 @@
+package providers
+
+// Usage reports token consumption for a single Chat call.
+type Usage struct {
+	// PromptTokens counts all input tokens, including images.
+	PromptTokens int `json:"promptTokens"`
+	// ImageTokens is the part of PromptTokens spent on images. It is taken from the provider
+	// when the API breaks it out (Gemini) and estimated from image dimensions otherwise.
+	ImageTokens int `json:"imageTokens,omitempty"`
+	// CompletionTokens counts visible output tokens, excluding ThinkingTokens.
+	CompletionTokens int `json:"completionTokens"`
+	// ThinkingTokens counts reasoning tokens where the provider reports them separately.
+	ThinkingTokens int `json:"thinkingTokens,omitempty"`
+	// Cached reports that the response came from the local cache or a replay; nothing was billed.
+	Cached bool `json:"cached,omitempty"`
+}
+
+// Add accumulates the token counts of o into u.
+func (u *Usage) Add(o Usage) {
+	u.PromptTokens += o.PromptTokens
+	u.ImageTokens += o.ImageTokens
+	u.CompletionTokens += o.CompletionTokens
+	u.ThinkingTokens += o.ThinkingTokens
+}
+
+// IsZero reports whether no tokens were recorded.
+func (u Usage) IsZero() bool {
+	u.Cached = false
+	return u == Usage{}
+}
+
+// OutputTokens returns completion plus thinking tokens, which providers bill at the output rate.
+func (u Usage) OutputTokens() int {
+	return u.CompletionTokens + u.ThinkingTokens
+}
+
+// withEstimatedImageTokens fills ImageTokens from image dimensions when the provider did not
+// report it, never exceeding the reported prompt total.
+func (u Usage) withEstimatedImageTokens(provider string, imgs [][]byte) Usage {
+	if u.ImageTokens > 0 || len(imgs) == 0 {
+		return u
+	}
+
+	u.ImageTokens = estimateImagesTokens(provider, imgs)
+	if u.PromptTokens > 0 && u.ImageTokens > u.PromptTokens {
+		u.ImageTokens = u.PromptTokens
+	}
+	return u
+}
@@ -0,0 +1,292 @@
+package providers_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func newAnthropicStub(t *testing.T, handler http.HandlerFunc) providers.Provider {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewAnthropic(providers.ProviderConfig{APIKey: "test", BaseURL: srv.URL + "/v1"})
+	if err != nil {
+		t.Fatalf("NewAnthropic: %v", err)
+	}
+	return p
+}
+
+// anthropicSSE writes events as a Messages API stream.
+func anthropicSSE(w http.ResponseWriter, events ...string) {
+	w.Header().Set("Content-Type", "text/event-stream")
+
+	for _, ev := range events {
+		var typed struct {
+			Type string `json:"type"`
+		}
+		_ = json.Unmarshal([]byte(ev), &typed)
+		_, _ = w.Write([]byte("event: " + typed.Type + "\ndata: " + ev + "\n\n"))
+	}
+}
+
+func TestAnthropic_SendsMessagesRequestAndReadsBlocks(t *testing.T) {
+	var (
+		body   map[string]any
+		header http.Header
+		path   string
+	)
+
+	p := newAnthropicStub(t, func(w http.ResponseWriter, r *http.Request) {
+		header, path = r.Header.Clone(), r.URL.Path
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		_, _ = w.Write([]byte(`{"content":[{"type":"thinking","thinking":"two cats"},{"type":"text","text":"[]"}],` +
+			`"stop_reason":"end_turn","usage":{"input_tokens":120,"output_tokens":9}}`))
+	})
+
+	var content, thinking string
+
+	opts := providers.NewChatOptions("claude", "detect",
+		providers.WithSystemPrompt("be precise"),
+		providers.WithImages([]byte("\x89PNG\r\n\x1a\n")),
+		providers.WithReasoningEffort("low"),
+		providers.WithTemperature(0.3),
+		providers.WithStop("END"),
+		providers.WithOnDelta(func(c, th string) error {
+			content += c
+			thinking += th
+			return nil
+		}),
+	)
+
+	usage, err := p.Chat(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if path != "/v1/messages" || header.Get("X-Api-Key") != "test" || header.Get("Anthropic-Version") == "" {
+		t.Fatalf("path = %s, headers = %v", path, header)
+	}
+
+	if usage.PromptTokens != 120 || usage.CompletionTokens != 9 {
+		t.Fatalf("usage = %+v", usage)
+	}
+
+	if content != "[]" || thinking != "two cats" {
+		t.Fatalf("content=%q thinking=%q", content, thinking)
+	}
+
+	if body["system"] != "be precise" || body["temperature"] != nil {
+		t.Fatalf("system = %v, temperature = %v; thinking must drop temperature", body["system"], body["temperature"])
+	}
+
+	thinkCfg, _ := body["thinking"].(map[string]any)
+	if thinkCfg["type"] != "enabled" || thinkCfg["budget_tokens"] != float64(1024) {
+		t.Fatalf("thinking = %v", body["thinking"])
+	}
+
+	if maxTokens, _ := body["max_tokens"].(float64); maxTokens <= 1024 {
+		t.Fatalf("max_tokens = %v, want more than the thinking budget", body["max_tokens"])
+	}
+
+	msgs, _ := body["messages"].([]any)
+	msg, _ := msgs[0].(map[string]any)
+	blocks, _ := msg["content"].([]any)
+	img, _ := blocks[0].(map[string]any)
+	source, _ := img["source"].(map[string]any)
+	if len(blocks) != 2 || img["type"] != "image" || source["media_type"] != "image/png" {
+		t.Fatalf("content blocks = %v", msg["content"])
+	}
+}
+
+func TestAnthropic_StreamsDeltasAndUsage(t *testing.T) {
+	var header http.Header
+
+	p := newAnthropicStub(t, func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		anthropicSSE(w,
+			`{"type":"message_start","message":{"content":[],"usage":{"input_tokens":50,"output_tokens":1}}}`,
+			`{"type":"content_block_start","index":0,"content_block":{"type":"thinking","thinking":""}}`,
+			`{"type":"content_block_delta","index":0,"delta":{"type":"thinking_delta","thinking":"hmm"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"[{\"label\":"}}`,
+			`{"type":"content_block_delta","index":1,"delta":{"type":"text_delta","text":"\"cat\"}]"}}`,
+			`{"type":"message_delta","delta":{"stop_reason":"end_turn"},"usage":{"output_tokens":12}}`,
+			`{"type":"message_stop"}`,
+		)
+	})
+
+	var content, thinking strings.Builder
+
+	opts := providers.NewChatOptions("claude", "detect",
+		providers.WithStream(true),
+		providers.WithOnDelta(func(c, th string) error {
+			content.WriteString(c)
+			thinking.WriteString(th)
+			return nil
+		}),
+	)
+
+	usage, err := p.Chat(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if header.Get("Accept") != "text/event-stream" {
+		t.Fatalf("Accept = %q", header.Get("Accept"))
+	}
+
+	if content.String() != `[{"label":"cat"}]` || thinking.String() != "hmm" {
+		t.Fatalf("content=%q thinking=%q", content.String(), thinking.String())
+	}
+
+	if usage.PromptTokens != 50 || usage.CompletionTokens != 12 {
+		t.Fatalf("usage = %+v", usage)
+	}
+}
+
+func TestAnthropic_IncompleteResponsesReturnErrors(t *testing.T) {
+	cases := []struct {
+		name   string
+		stream bool
+		write  func(w http.ResponseWriter)
+		reason string // stop_reason of the expected *AnthropicStopError; empty expects a cut stream
+	}{
+		{
+			name: "max tokens",
+			write: func(w http.ResponseWriter) {
+				_, _ = w.Write([]byte(`{"content":[{"type":"text","text":"[{\"la"}],"stop_reason":"max_tokens"}`))
+			},
+			reason: "max_tokens",
+		},
+		{
+			name:   "max tokens streamed",
+			stream: true,
+			write: func(w http.ResponseWriter) {
+				anthropicSSE(w,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"[{\"la"}}`,
+					`{"type":"message_delta","delta":{"stop_reason":"max_tokens"},"usage":{"output_tokens":4096}}`,
+					`{"type":"message_stop"}`,
+				)
+			},
+			reason: "max_tokens",
+		},
+		{
+			name: "refusal",
+			write: func(w http.ResponseWriter) {
+				_, _ = w.Write([]byte(`{"content":[],"stop_reason":"refusal"}`))
+			},
+			reason: "refusal",
+		},
+		{
+			name:   "cut stream",
+			stream: true,
+			write: func(w http.ResponseWriter) {
+				anthropicSSE(w, `{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"[{\"la"}}`)
+			},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newAnthropicStub(t, func(w http.ResponseWriter, r *http.Request) { tc.write(w) })
+
+			_, err := p.Chat(context.Background(), providers.NewChatOptions("claude", "p", providers.WithStream(tc.stream)))
+
+			if tc.reason == "" {
+				if !errors.Is(err, io.ErrUnexpectedEOF) || !providers.IsRetryable(err) {
+					t.Fatalf("err = %v, want a retryable io.ErrUnexpectedEOF", err)
+				}
+				return
+			}
+
+			var stopped *providers.AnthropicStopError
+			if !errors.As(err, &stopped) || stopped.Reason != tc.reason {
+				t.Fatalf("err = %v, want *AnthropicStopError %s", err, tc.reason)
+			}
+
+			if providers.IsRetryable(err) {
+				t.Fatal("an incomplete answer should not be retried")
+			}
+
+			filtered := errors.Is(providers.Classify("anthropic", err), providers.ErrContentFiltered)
+			if filtered != (tc.reason == "refusal") {
+				t.Fatalf("classified as filtered = %t", filtered)
+			}
+		})
+	}
+}
+
+func TestAnthropic_AuthTokenAndErrorStatus(t *testing.T) {
+	var auth, apiKey string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth, apiKey = r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+		_, _ = w.Write([]byte(`{"type":"error","error":{"type":"rate_limit_error","message":"slow down"}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewAnthropic(providers.ProviderConfig{APIKey: "tok", BaseURL: srv.URL, AuthType: "auth_token"})
+	if err != nil {
+		t.Fatalf("NewAnthropic: %v", err)
+	}
+
+	_, err = p.Chat(context.Background(), providers.NewChatOptions("claude", "p"))
+	if !errors.Is(providers.Classify("anthropic", err), providers.ErrRateLimited) {
+		t.Fatalf("err = %v, want rate limited", err)
+	}
+
+	if auth != "Bearer tok" || apiKey != "" {
+		t.Fatalf("Authorization = %q, X-Api-Key = %q", auth, apiKey)
+	}
+}
+
+func TestAnthropic_StreamErrorEventsAreClassified(t *testing.T) {
+	cases := []struct {
+		errType   string
+		status    int
+		kind      error
+		retryable bool
+	}{
+		{"overloaded_error", 529, providers.ErrTransient, true},
+		{"rate_limit_error", http.StatusTooManyRequests, providers.ErrRateLimited, true},
+		{"api_error", http.StatusInternalServerError, providers.ErrTransient, true},
+		{"authentication_error", http.StatusUnauthorized, providers.ErrAuth, false},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.errType, func(t *testing.T) {
+			p := newAnthropicStub(t, func(w http.ResponseWriter, r *http.Request) {
+				anthropicSSE(w,
+					`{"type":"content_block_delta","index":0,"delta":{"type":"text_delta","text":"[{\"la"}}`,
+					`{"type":"error","error":{"type":"`+tc.errType+`","message":"try later"}}`,
+				)
+			})
+
+			_, err := p.Chat(context.Background(), providers.NewChatOptions("claude", "p", providers.WithStream(true)))
+
+			if got := providers.StatusCode(err); got != tc.status {
+				t.Fatalf("StatusCode = %d, want %d (err %v)", got, tc.status, err)
+			}
+
+			if !errors.Is(providers.Classify("anthropic", err), tc.kind) {
+				t.Fatalf("err = %v, want kind %v", err, tc.kind)
+			}
+
+			if providers.IsRetryable(err) != tc.retryable {
+				t.Fatalf("IsRetryable = %t, want %t", providers.IsRetryable(err), tc.retryable)
+			}
+		})
+	}
+}
@@ -0,0 +1,139 @@
+package providers_test
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestWithCache_ServesIdenticalRequestsFromDisk(t *testing.T) {
+	var calls atomic.Int32
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]}}],` +
+			`"usageMetadata":{"promptTokenCount":10,"candidatesTokenCount":1}}`))
+	})
+
+	cache, err := providers.NewResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+
+	cp := providers.WithCache(p, "gemini", providers.ProviderConfig{}, cache)
+	chat := func(temp float64) (string, providers.Usage) {
+		t.Helper()
+
+		var out string
+
+		opts := providers.NewChatOptions("m", "detect",
+			providers.WithTemperature(temp),
+			providers.WithImages([]byte("img")),
+			providers.WithOnDelta(func(content, _ string) error {
+				out += content
+				return nil
+			}),
+		)
+
+		usage, err := cp.Chat(context.Background(), opts)
+		if err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+		return out, usage
+	}
+
+	if _, usage := chat(0.2); usage.Cached || usage.PromptTokens != 10 {
+		t.Fatalf("first call usage = %+v, want a billed miss", usage)
+	}
+
+	if out, usage := chat(0.2); out != "[]" || !usage.Cached || !usage.IsZero() {
+		t.Fatalf("second call = %q %+v, want cached hit", out, usage)
+	}
+
+	if _, usage := chat(0.7); usage.Cached {
+		t.Fatal("changing temperature must miss the cache")
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+
+	removed, _, err := cache.Prune(0)
+	if err != nil || removed != 2 {
+		t.Fatalf("Prune = %d, %v; want 2 entries", removed, err)
+	}
+}
+
+func TestWithCache_SkipsResponsesTheCallerRejects(t *testing.T) {
+	var calls atomic.Int32
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"no boxes"}]}}]}`))
+	})
+
+	cache, err := providers.NewResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+
+	cp := providers.WithCache(p, "gemini", providers.ProviderConfig{}, cache)
+	opts := providers.NewChatOptions("m", "detect",
+		providers.WithCacheable(func(content string) bool { return content == "[]" }),
+	)
+
+	for range 2 {
+		usage, err := cp.Chat(context.Background(), opts)
+		if err != nil || usage.Cached {
+			t.Fatalf("Chat = %+v, %v; want an uncached answer", usage, err)
+		}
+	}
+
+	if calls.Load() != 2 {
+		t.Fatalf("calls = %d, want 2", calls.Load())
+	}
+}
+
+func TestWithCache_SeparatesEndpoints(t *testing.T) {
+	var calls atomic.Int32
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]}}]}`))
+	})
+
+	cache, err := providers.NewResponseCache(t.TempDir())
+	if err != nil {
+		t.Fatalf("NewResponseCache: %v", err)
+	}
+
+	configs := []providers.ProviderConfig{
+		{BaseURL: "https://a.example"},
+		{BaseURL: "https://b.example"},
+		{BaseURL: "https://b.example/"},
+		{BaseURL: "https://b.example", Gemini: providers.GeminiConfig{
+			SafetySettings: []providers.GeminiSafetySetting{{Category: "HARM_CATEGORY_HARASSMENT", Threshold: "BLOCK_NONE"}},
+		}},
+	}
+	want := []bool{false, false, true, false}
+
+	for i, cfg := range configs {
+		cp := providers.WithCache(p, "gemini", cfg, cache)
+
+		usage, err := cp.Chat(context.Background(), providers.NewChatOptions("m", "p"))
+		if err != nil {
+			t.Fatalf("Chat: %v", err)
+		}
+
+		if usage.Cached != want[i] {
+			t.Errorf("config %d: cached = %t, want %t", i, usage.Cached, want[i])
+		}
+	}
+
+	if calls.Load() != 3 {
+		t.Fatalf("calls = %d, want 3", calls.Load())
+	}
+}
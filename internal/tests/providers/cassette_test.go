@@ -0,0 +1,58 @@
+package providers_test
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync/atomic"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestCassette_RecordThenReplayOffline(t *testing.T) {
+	var calls atomic.Int32
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[{\"label\":\"cat\"}]"}]}}]}`))
+	})
+
+	dir := t.TempDir()
+
+	rec, err := providers.WithRecorder(p, dir)
+	if err != nil {
+		t.Fatalf("WithRecorder: %v", err)
+	}
+
+	img := []byte("\x89PNG\r\n\x1a\n")
+	opts := providers.NewChatOptions("m", "detect", providers.WithImages(img))
+
+	if _, err := rec.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("record: %v", err)
+	}
+
+	replay, err := providers.NewReplayer(dir)
+	if err != nil {
+		t.Fatalf("NewReplayer: %v", err)
+	}
+
+	var got string
+
+	opts.OnDelta = func(content, _ string) error {
+		got += content
+		return nil
+	}
+	if _, err := replay.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("replay: %v", err)
+	}
+
+	if got != `[{"label":"cat"}]` || calls.Load() != 1 {
+		t.Fatalf("replayed %q after %d calls", got, calls.Load())
+	}
+
+	other := providers.NewChatOptions("m", "detect", providers.WithImages([]byte("other")))
+	if _, err := replay.Chat(context.Background(), other); !errors.Is(err, providers.ErrCassetteMiss) {
+		t.Fatalf("err = %v, want ErrCassetteMiss", err)
+	}
+}
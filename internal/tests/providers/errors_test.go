@@ -0,0 +1,101 @@
+package providers_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestClassify_MapsProviderFailuresToKinds(t *testing.T) {
+	cases := []struct {
+		name       string
+		status     int
+		body       string
+		retryAfter string
+		kind       error
+	}{
+		{"auth", http.StatusUnauthorized, `{"error":{"code":401,"message":"unauthenticated"}}`, "", providers.ErrAuth},
+		{
+			"bad key as 400", http.StatusBadRequest,
+			`{"error":{"code":400,"message":"API key not valid. Please pass a valid API key."}}`, "", providers.ErrAuth,
+		},
+		{
+			"model", http.StatusNotFound,
+			`{"error":{"code":404,"message":"models/gemini-9 is not found for API version v1beta"}}`, "",
+			providers.ErrModelNotFound,
+		},
+		{
+			"context", http.StatusBadRequest,
+			`{"error":{"code":400,"message":"The input token count exceeds the maximum"}}`, "", providers.ErrContextTooLong,
+		},
+		{"rate limit", http.StatusTooManyRequests, `{"error":{"code":429,"message":"quota"}}`, "7", providers.ErrRateLimited},
+		{"overloaded", http.StatusServiceUnavailable, `{"error":{"code":503}}`, "", providers.ErrTransient},
+		{"filtered", http.StatusOK, `{"promptFeedback":{"blockReason":"SAFETY"}}`, "", providers.ErrContentFiltered},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.status)
+				_, _ = w.Write([]byte(tc.body))
+			})
+
+			_, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p"))
+			err = providers.Classify("gemini", err)
+
+			if !errors.Is(err, tc.kind) {
+				t.Fatalf("err = %v, want kind %v", err, tc.kind)
+			}
+
+			var pErr *providers.Error
+			if !errors.As(err, &pErr) || pErr.Provider != "gemini" {
+				t.Fatalf("err = %#v, want *providers.Error from gemini", err)
+			}
+
+			if tc.status != http.StatusOK && pErr.StatusCode != tc.status {
+				t.Fatalf("StatusCode = %d, want %d", pErr.StatusCode, tc.status)
+			}
+
+			if tc.retryAfter != "" && pErr.RetryAfter != 7*time.Second {
+				t.Fatalf("RetryAfter = %s, want 7s", pErr.RetryAfter)
+			}
+
+			if pErr.Retryable() != providers.IsRetryable(err) {
+				t.Fatalf("Retryable() = %t disagrees with IsRetryable", pErr.Retryable())
+			}
+		})
+	}
+}
+
+func TestClassify_LeavesUnknownAndCanceledErrorsAlone(t *testing.T) {
+	cut := providers.Classify("x", fmt.Errorf("read: %w", io.ErrUnexpectedEOF))
+	if !errors.Is(cut, providers.ErrTransient) {
+		t.Fatalf("cut stream = %v, want transient", cut)
+	}
+
+	// A 404 that is not about the model, such as an expired upload, is not a model error.
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+		_, _ = w.Write([]byte(`{"error":{"code":404,"status":"NOT_FOUND","message":"File abc123 was not found"}}`))
+	})
+
+	_, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p"))
+	if got := providers.Classify("gemini", err); got != err {
+		t.Fatalf("Classify(file 404) = %v, want unchanged", got)
+	}
+
+	for _, err := range []error{context.Canceled, errors.New("parse failure")} {
+		if got := providers.Classify("x", err); got != err {
+			t.Fatalf("Classify(%v) = %v, want unchanged", err, got)
+		}
+	}
+}
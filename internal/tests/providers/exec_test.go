@@ -0,0 +1,202 @@
+package providers_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+// TestExecHelperProcess is the plugin launched by the exec tests; it does nothing when run
+// as a normal test.
+func TestExecHelperProcess(t *testing.T) {
+	if os.Getenv("DINO_EXEC_HELPER") != "1" {
+		return
+	}
+
+	in := bufio.NewScanner(os.Stdin)
+	in.Buffer(nil, 1<<20)
+
+	answer := func(id uint64, images int, seed *int64) {
+		out, _ := json.Marshal(map[string]any{
+			"pid": os.Getpid(), "images": images, "seed": seed, "key": os.Getenv("DINO_EXEC_API_KEY"),
+		})
+		fmt.Printf(`{"id":%d,"thinking":"looking"}`+"\n", id)
+		fmt.Printf(`{"id":%d,"content":%q}`+"\n", id, out[:5])
+		fmt.Printf(`{"id":%d,"content":%q}`+"\n", id, out[5:])
+		fmt.Printf(`{"id":%d,"done":true,"usage":{"promptTokens":11,"completionTokens":3}}`+"\n", id)
+	}
+
+	// "gather" requests are answered last-first once three have arrived, so they only finish
+	// when the caller keeps several in flight.
+	var gathered []uint64
+
+	for in.Scan() {
+		var req struct {
+			ID      uint64   `json:"id"`
+			Prompt  string   `json:"prompt"`
+			Images  []string `json:"images"`
+			Options struct {
+				Seed *int64 `json:"seed"`
+			} `json:"options"`
+		}
+		_ = json.Unmarshal(in.Bytes(), &req)
+
+		switch req.Prompt {
+		case "fail":
+			fmt.Printf(`{"id":%d,"error":"busy","status":503}`+"\n", req.ID)
+			continue
+		case "crash":
+			fmt.Printf(`{"id":%d,"content":"[{"}`+"\n", req.ID)
+			os.Exit(3)
+		case "gather":
+			if gathered = append(gathered, req.ID); len(gathered) < 3 {
+				continue
+			}
+
+			for _, id := range slices.Backward(gathered) {
+				answer(id, len(req.Images), req.Options.Seed)
+			}
+
+			gathered = nil
+			continue
+		}
+
+		answer(req.ID, len(req.Images), req.Options.Seed)
+	}
+	os.Exit(0)
+}
+
+func newExecHelper(t *testing.T) providers.Provider {
+	t.Helper()
+
+	p, err := providers.NewExec(providers.ProviderConfig{
+		APIKey: "k",
+		Exec: providers.ExecConfig{
+			Command: []string{os.Args[0], "-test.run=^TestExecHelperProcess$"},
+			Env:     []string{"DINO_EXEC_HELPER=1"},
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewExec: %v", err)
+	}
+	return p
+}
+
+func TestExec_ExchangesNDJSONAndRestartsAfterCrash(t *testing.T) {
+	p := newExecHelper(t)
+
+	type reply struct {
+		PID    int    `json:"pid"`
+		Images int    `json:"images"`
+		Seed   *int64 `json:"seed"`
+		Key    string `json:"key"`
+	}
+
+	chat := func(prompt string) (reply, string, providers.Usage, error) {
+		t.Helper()
+
+		var content, thinking string
+
+		opts := providers.NewChatOptions("detector", prompt,
+			providers.WithImages([]byte("img")),
+			providers.WithSeed(9),
+			providers.WithOnDelta(func(c, th string) error {
+				content += c
+				thinking += th
+				return nil
+			}),
+		)
+
+		usage, err := p.Chat(context.Background(), opts)
+
+		var r reply
+		if err == nil {
+			if jerr := json.Unmarshal([]byte(content), &r); jerr != nil {
+				t.Fatalf("content %q: %v", content, jerr)
+			}
+		}
+		return r, thinking, usage, err
+	}
+
+	first, thinking, usage, err := chat("detect")
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if first.Images != 1 || first.Seed == nil || *first.Seed != 9 || first.Key != "k" || thinking != "looking" {
+		t.Fatalf("reply = %+v, thinking = %q", first, thinking)
+	}
+
+	if usage != (providers.Usage{PromptTokens: 11, CompletionTokens: 3}) {
+		t.Fatalf("usage = %+v", usage)
+	}
+
+	_, _, _, err = chat("fail")
+	if providers.StatusCode(err) != 503 || !providers.IsRetryable(err) {
+		t.Fatalf("reported error = %v, want retryable 503", err)
+	}
+
+	again, _, _, err := chat("detect")
+	if err != nil || again.PID != first.PID {
+		t.Fatalf("after reported error: pid %d (was %d), err %v", again.PID, first.PID, err)
+	}
+
+	_, _, _, err = chat("crash")
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("crash err = %v, want unexpected EOF", err)
+	}
+
+	restarted, _, _, err := chat("detect")
+	if err != nil || restarted.PID == first.PID {
+		t.Fatalf("after crash: pid %d (was %d), err %v", restarted.PID, first.PID, err)
+	}
+}
+
+func TestExec_RoutesConcurrentRequestsByID(t *testing.T) {
+	p := newExecHelper(t)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	var (
+		wg   sync.WaitGroup
+		errs = make([]error, 3)
+		outs = make([]string, 3)
+	)
+
+	for i := range 3 {
+		wg.Go(func() {
+			var content strings.Builder
+
+			opts := providers.NewChatOptions("detector", "gather", providers.WithOnDelta(func(c, _ string) error {
+				content.WriteString(c)
+				return nil
+			}))
+
+			_, errs[i] = p.Chat(ctx, opts)
+			outs[i] = content.String()
+		})
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("call %d: %v; a pending request must not block the others", i, err)
+		}
+
+		if !json.Valid([]byte(outs[i])) {
+			t.Fatalf("call %d content %q mixes in another response", i, outs[i])
+		}
+	}
+}
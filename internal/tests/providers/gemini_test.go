@@ -0,0 +1,369 @@
+package providers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestGemini_ThinkingConfigThoughtPartsAndUsage(t *testing.T) {
+	var body map[string]any
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[` +
+			`{"text":"looking at the image","thought":true},{"text":"[]"}]}}],` +
+			`"usageMetadata":{"promptTokenCount":300,"candidatesTokenCount":2,"thoughtsTokenCount":40,` +
+			`"promptTokensDetails":[{"modality":"TEXT","tokenCount":42},{"modality":"IMAGE","tokenCount":258}]}}`))
+	})
+
+	var content, thinking string
+
+	opts := providers.NewChatOptions("m", "p",
+		providers.WithReasoningEffort("low"),
+		providers.WithOnDelta(func(c, th string) error {
+			content += c
+			thinking += th
+			return nil
+		}),
+	)
+	usage, err := p.Chat(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	want := providers.Usage{PromptTokens: 300, ImageTokens: 258, CompletionTokens: 2, ThinkingTokens: 40}
+	if usage != want {
+		t.Fatalf("usage = %+v, want %+v", usage, want)
+	}
+
+	genCfg, _ := body["generationConfig"].(map[string]any)
+	thinkCfg, _ := genCfg["thinkingConfig"].(map[string]any)
+	if thinkCfg["thinkingBudget"] != float64(1024) || thinkCfg["includeThoughts"] != true {
+		t.Fatalf("thinkingConfig = %v", thinkCfg)
+	}
+
+	if content != "[]" || thinking != "looking at the image" {
+		t.Fatalf("content=%q thinking=%q", content, thinking)
+	}
+}
+
+func TestGemini_MessagesMapToUserAndModelContents(t *testing.T) {
+	var body struct {
+		Contents []struct {
+			Role  string           `json:"role"`
+			Parts []map[string]any `json:"parts"`
+		} `json:"contents"`
+	}
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]}}]}`))
+	})
+
+	opts := providers.NewChatOptions("m", "detect again",
+		providers.WithImages([]byte("\x89PNG\r\n\x1a\n")),
+		providers.WithMessages(
+			providers.Message{Role: providers.RoleUser, Content: "example", Images: [][]byte{[]byte("\x89PNG\r\n\x1a\n")}},
+			providers.Message{Role: providers.RoleAssistant, Content: `[{"label":"cat"}]`},
+		),
+	)
+	if _, err := p.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if len(body.Contents) != 3 {
+		t.Fatalf("contents = %+v, want 3 turns", body.Contents)
+	}
+
+	for i, want := range []struct {
+		role  string
+		parts int
+	}{{"user", 2}, {"model", 1}, {"user", 2}} {
+		if got := body.Contents[i]; got.Role != want.role || len(got.Parts) != want.parts {
+			t.Fatalf("contents[%d] = %s with %d parts, want %s with %d", i, got.Role, len(got.Parts), want.role, want.parts)
+		}
+	}
+}
+
+func TestGemini_ListModelsFollowsPagesAndFindsConfiguredModel(t *testing.T) {
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/v1beta/models" {
+			http.NotFound(w, r)
+			return
+		}
+
+		if r.URL.Query().Get("pageToken") == "" {
+			_, _ = w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-flash","displayName":"Gemini 2.5 Flash",` +
+				`"supportedGenerationMethods":["generateContent"]},{"name":"models/text-embedding-004",` +
+				`"supportedGenerationMethods":["embedContent"]}],"nextPageToken":"p2"}`))
+
+			return
+		}
+		_, _ = w.Write([]byte(`{"models":[{"name":"models/gemini-2.5-pro",` +
+			`"supportedGenerationMethods":["generateContent"]}]}`))
+	})
+
+	models, err := p.(providers.ModelLister).ListModels(context.Background())
+	if err != nil {
+		t.Fatalf("ListModels: %v", err)
+	}
+
+	if len(models) != 2 {
+		t.Fatalf("models = %+v, want the two generateContent models", models)
+	}
+
+	if m, ok := providers.FindModel(models, "models/gemini-2.5-flash"); !ok || m.DisplayName != "Gemini 2.5 Flash" {
+		t.Fatalf("FindModel = %+v, %v", m, ok)
+	}
+
+	if _, ok := providers.FindModel(models, "gemini-2.5-flsh"); ok {
+		t.Fatal("typo must not match")
+	}
+}
+
+func TestGemini_BlockedResponsesReturnTypedErrors(t *testing.T) {
+	cases := []struct {
+		name, resp, reason string
+		prompt             bool
+	}{
+		{
+			name: "prompt blocked",
+			resp: `{"promptFeedback":{"blockReason":"SAFETY","safetyRatings":[` +
+				`{"category":"HARM_CATEGORY_DANGEROUS_CONTENT","probability":"HIGH","blocked":true}]}}`,
+			reason: "SAFETY",
+			prompt: true,
+		},
+		{
+			name:   "recitation",
+			resp:   `{"candidates":[{"content":{"parts":[]},"finishReason":"RECITATION"}]}`,
+			reason: "RECITATION",
+		},
+		{
+			name:   "max tokens",
+			resp:   `{"candidates":[{"content":{"parts":[{"text":"[{\"label\""}]},"finishReason":"MAX_TOKENS"}]}`,
+			reason: "MAX_TOKENS",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body map[string]any
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				raw, _ := io.ReadAll(r.Body)
+				_ = json.Unmarshal(raw, &body)
+				_, _ = w.Write([]byte(tc.resp))
+			}))
+			t.Cleanup(srv.Close)
+
+			p, err := providers.NewGemini(providers.ProviderConfig{
+				APIKey:  "test",
+				BaseURL: srv.URL,
+				Gemini: providers.GeminiConfig{SafetySettings: []providers.GeminiSafetySetting{
+					{Category: "HARM_CATEGORY_DANGEROUS_CONTENT", Threshold: "BLOCK_ONLY_HIGH"},
+				}},
+			})
+			if err != nil {
+				t.Fatalf("NewGemini: %v", err)
+			}
+
+			_, err = p.Chat(context.Background(), providers.NewChatOptions("m", "p"))
+
+			var blocked *providers.GeminiBlockedError
+			if !errors.As(err, &blocked) {
+				t.Fatalf("err = %v, want *GeminiBlockedError", err)
+			}
+
+			if blocked.Reason != tc.reason || blocked.Prompt != tc.prompt {
+				t.Fatalf("blocked = %+v", blocked)
+			}
+
+			if providers.IsRetryable(err) {
+				t.Fatalf("blocked response should not be retryable")
+			}
+
+			settings, _ := body["safetySettings"].([]any)
+			if len(settings) != 1 {
+				t.Fatalf("safetySettings = %v", body["safetySettings"])
+			}
+		})
+	}
+}
+
+func TestGemini_LargeImagesUploadOnceAndAreReferencedAcrossRetries(t *testing.T) {
+	var (
+		starts, uploads, calls atomic.Int32
+		srvURL                 string
+		parts                  []map[string]any
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload/v1beta/files":
+			starts.Add(1)
+
+			if r.Header.Get("X-Goog-Upload-Command") != "start" || r.Header.Get("X-Goog-Upload-Header-Content-Length") != "64" {
+				t.Errorf("start headers = %v", r.Header)
+			}
+			w.Header().Set("X-Goog-Upload-URL", srvURL+"/session/1")
+		case "/session/1":
+			uploads.Add(1)
+
+			if raw, _ := io.ReadAll(r.Body); len(raw) != 64 {
+				t.Errorf("uploaded %d bytes, want 64", len(raw))
+			}
+			_, _ = w.Write([]byte(`{"file":{"name":"files/abc","uri":"` + srvURL + `/v1beta/files/abc",` +
+				`"mimeType":"image/png","state":"ACTIVE"}}`))
+		case "/v1beta/models/m:generateContent":
+			var body struct {
+				Contents []struct {
+					Parts []map[string]any `json:"parts"`
+				} `json:"contents"`
+			}
+			raw, _ := io.ReadAll(r.Body)
+			_ = json.Unmarshal(raw, &body)
+			parts = body.Contents[0].Parts
+
+			if calls.Add(1) == 1 {
+				w.Header().Set("Retry-After", "0")
+				http.Error(w, `{"error":{"code":503,"status":"UNAVAILABLE","message":"overloaded"}}`, http.StatusServiceUnavailable)
+				return
+			}
+			_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]},"finishReason":"STOP"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	srvURL = srv.URL
+
+	p, err := providers.NewGemini(providers.ProviderConfig{
+		APIKey:  "test",
+		BaseURL: srv.URL,
+		Gemini:  providers.GeminiConfig{UploadThreshold: 32},
+	})
+	if err != nil {
+		t.Fatalf("NewGemini: %v", err)
+	}
+
+	large := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 56)...)
+	small := []byte("\x89PNG\r\n\x1a\nsmall")
+
+	rp := providers.WithRetry(p, providers.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+
+	opts := providers.NewChatOptions("m", "p", providers.WithImages(large, small))
+	if _, err := rp.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if calls.Load() != 2 || starts.Load() != 1 || uploads.Load() != 1 {
+		t.Fatalf("calls=%d starts=%d uploads=%d, want 2/1/1", calls.Load(), starts.Load(), uploads.Load())
+	}
+
+	if len(parts) != 3 {
+		t.Fatalf("parts = %v", parts)
+	}
+
+	fileData, _ := parts[1]["file_data"].(map[string]any)
+	if fileData["file_uri"] != srv.URL+"/v1beta/files/abc" || fileData["mime_type"] != "image/png" {
+		t.Fatalf("large image part = %v", parts[1])
+	}
+
+	if _, ok := parts[2]["inline_data"]; !ok {
+		t.Fatalf("small image part = %v, want inline_data", parts[2])
+	}
+}
+
+func TestGemini_ConcurrentCallsShareOneUploadOfTheSameImage(t *testing.T) {
+	var (
+		starts, uploads, calls atomic.Int32
+		srvURL                 string
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/upload/v1beta/files":
+			starts.Add(1)
+			w.Header().Set("X-Goog-Upload-URL", srvURL+"/session/1")
+		case "/session/1":
+			uploads.Add(1)
+			// Slow enough that every call arrives while the upload is in progress.
+			time.Sleep(100 * time.Millisecond)
+			_, _ = w.Write([]byte(`{"file":{"name":"files/abc","uri":"` + srvURL + `/v1beta/files/abc",` +
+				`"mimeType":"image/png","state":"ACTIVE"}}`))
+		case "/v1beta/models/m:generateContent":
+			calls.Add(1)
+			_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]},"finishReason":"STOP"}]}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+	srvURL = srv.URL
+
+	p, err := providers.NewGemini(providers.ProviderConfig{
+		APIKey:  "test",
+		BaseURL: srv.URL,
+		Gemini:  providers.GeminiConfig{UploadThreshold: 32},
+	})
+	if err != nil {
+		t.Fatalf("NewGemini: %v", err)
+	}
+
+	large := append([]byte("\x89PNG\r\n\x1a\n"), bytes.Repeat([]byte{0}, 56)...)
+
+	opts := providers.NewChatOptions("m", "p", providers.WithImages(large))
+
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Go(func() {
+			if _, err := p.Chat(context.Background(), opts); err != nil {
+				t.Errorf("Chat: %v", err)
+			}
+		})
+	}
+	wg.Wait()
+
+	if calls.Load() != 4 || starts.Load() != 1 || uploads.Load() != 1 {
+		t.Fatalf("calls=%d starts=%d uploads=%d, want 4/1/1", calls.Load(), starts.Load(), uploads.Load())
+	}
+}
+
+func TestGemini_OutputControlsMapToGenerationConfig(t *testing.T) {
+	var body struct {
+		GenerationConfig map[string]any `json:"generationConfig"`
+	}
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]},"finishReason":"STOP"}]}`))
+	})
+
+	opts := providers.NewChatOptions("m", "p",
+		providers.WithMaxTokens(256),
+		providers.WithSeed(7),
+		providers.WithStop("END"),
+	)
+	if _, err := p.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	cfg := body.GenerationConfig
+	stop, _ := cfg["stopSequences"].([]any)
+	if cfg["maxOutputTokens"] != float64(256) || cfg["seed"] != float64(7) || len(stop) != 1 || stop[0] != "END" {
+		t.Fatalf("generationConfig = %v", cfg)
+	}
+}
@@ -0,0 +1,159 @@
+package providers_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"image"
+	"image/png"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestMock_FixtureByImageName(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "cat.json", `[{"label":"cat","bbox":[1,2,3,4]}]`)
+
+	p, err := providers.New("mock", providers.ProviderConfig{Mock: providers.MockConfig{Fixtures: dir, ChunkSize: 5}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var (
+		sb     strings.Builder
+		chunks int
+	)
+
+	opts := providers.NewChatOptions("m", "detect",
+		providers.WithStream(true),
+		providers.WithImages([]byte("not decoded")),
+		providers.WithImageNames("cat.jpg"),
+		providers.WithOnDelta(func(content, _ string) error {
+			chunks++
+			sb.WriteString(content)
+			return nil
+		}),
+	)
+	if _, err := p.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if sb.String() != `[{"label":"cat","bbox":[1,2,3,4]}]` || chunks < 2 {
+		t.Fatalf("got %q in %d chunks", sb.String(), chunks)
+	}
+}
+
+// writeFixture saves content as the fixture name under dir.
+func writeFixture(t *testing.T, dir, name, content string) {
+	t.Helper()
+
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestMock_FixtureByRelativePathBeforeBaseName(t *testing.T) {
+	dir := t.TempDir()
+	writeFixture(t, dir, "a/x.json", `[{"label":"a","bbox":[1,2,3,4]}]`)
+	writeFixture(t, dir, "b/x.jpg.json", `[{"label":"b","bbox":[1,2,3,4]}]`)
+	writeFixture(t, dir, "x.json", `[{"label":"any","bbox":[1,2,3,4]}]`)
+
+	p, err := providers.New("mock", providers.ProviderConfig{Mock: providers.MockConfig{Fixtures: dir}})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	for name, want := range map[string]string{
+		"a/x.jpg":     "a",
+		"b/x.jpg":     "b",
+		"c/x.jpg":     "any", // no fixture for the path, so the base name is used
+		"../a/x.jpg":  "any", // never looked up outside the fixture directory
+		"x.jpg":       "any",
+		"a/b/c/x.jpg": "any",
+	} {
+		var sb strings.Builder
+
+		opts := providers.NewChatOptions("m", "detect",
+			providers.WithImages([]byte("not decoded")),
+			providers.WithImageNames(name),
+			providers.WithOnDelta(func(content, _ string) error {
+				sb.WriteString(content)
+				return nil
+			}),
+		)
+		if _, err := p.Chat(context.Background(), opts); err != nil {
+			t.Fatalf("%s: Chat: %v", name, err)
+		}
+
+		var dets []struct {
+			Label string `json:"label"`
+		}
+		if err := json.Unmarshal([]byte(sb.String()), &dets); err != nil || len(dets) != 1 || dets[0].Label != want {
+			t.Errorf("%s: got %s, want the %q fixture", name, sb.String(), want)
+		}
+	}
+}
+
+func TestMock_GeneratesBoxesWithinImage(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 64, 48))); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := providers.New("mock", providers.ProviderConfig{
+		Mock: providers.MockConfig{Classes: []string{"person"}, MaxBoxes: 20},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	var out string
+
+	opts := providers.NewChatOptions("m", "detect",
+		providers.WithImages(buf.Bytes()),
+		providers.WithOnDelta(func(content, _ string) error {
+			out += content
+			return nil
+		}),
+	)
+	if _, err := p.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	var dets []struct {
+		Label string `json:"label"`
+		BBox  []int  `json:"bbox"`
+	}
+	if err := json.Unmarshal([]byte(out), &dets); err != nil {
+		t.Fatalf("output %q: %v", out, err)
+	}
+
+	for _, d := range dets {
+		if d.Label != "person" || d.BBox[0] < 0 || d.BBox[1] < 0 || d.BBox[2] > 64 || d.BBox[3] > 48 {
+			t.Fatalf("bad detection %+v", d)
+		}
+	}
+}
+
+func TestMock_InjectedErrorIsRetryable(t *testing.T) {
+	p, err := providers.New("mock", providers.ProviderConfig{
+		Mock: providers.MockConfig{ErrorRate: 1, ErrorStatus: 503},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	_, err = p.Chat(context.Background(), providers.NewChatOptions("m", "detect"))
+	if providers.StatusCode(err) != 503 || !providers.IsRetryable(err) {
+		t.Fatalf("err = %v, want retryable 503", err)
+	}
+}
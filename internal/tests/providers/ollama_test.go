@@ -0,0 +1,161 @@
+package providers_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestOllama_BaseURLKeepAliveAndAuth(t *testing.T) {
+	cases := []struct {
+		name      string
+		baseURL   func(srv *httptest.Server) string
+		authType  string
+		keepAlive string
+		wantAuth  string // Authorization header
+		wantKey   string // X-Api-Key header
+		wantAlive any    // keep_alive in the request body
+	}{
+		{
+			name:      "bare host and port",
+			baseURL:   func(srv *httptest.Server) string { return strings.TrimPrefix(srv.URL, "http://") },
+			keepAlive: "5m",
+			wantAuth:  "Bearer secret",
+			wantAlive: "5m0s",
+		},
+		{
+			name:      "trailing api path",
+			baseURL:   func(srv *httptest.Server) string { return srv.URL + "/api/" },
+			authType:  "auth_token",
+			keepAlive: "-1",
+			wantAuth:  "Bearer secret",
+			wantAlive: float64(-1),
+		},
+		{
+			name:      "api key header",
+			baseURL:   func(srv *httptest.Server) string { return srv.URL },
+			authType:  "api_key",
+			keepAlive: "300",
+			wantKey:   "secret",
+			wantAlive: "5m0s",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var (
+				path, auth, apiKey string
+				body               map[string]any
+			)
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				path, auth, apiKey = r.URL.Path, r.Header.Get("Authorization"), r.Header.Get("X-Api-Key")
+				_ = json.NewDecoder(r.Body).Decode(&body)
+				_, _ = w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"[]"},"done":true}`))
+			}))
+			t.Cleanup(srv.Close)
+
+			p, err := providers.NewOllamaFromConfig(providers.ProviderConfig{
+				APIKey:   "secret",
+				BaseURL:  tc.baseURL(srv),
+				AuthType: tc.authType,
+				Ollama:   providers.OllamaConfig{KeepAlive: tc.keepAlive, NumCtx: 8192},
+			})
+			if err != nil {
+				t.Fatalf("NewOllamaFromConfig: %v", err)
+			}
+
+			var content string
+
+			opts := providers.NewChatOptions("m", "p", providers.WithOnDelta(func(c, _ string) error {
+				content += c
+				return nil
+			}))
+			if _, err := p.Chat(context.Background(), opts); err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+
+			if path != "/api/chat" || content != "[]" {
+				t.Fatalf("path = %q content = %q", path, content)
+			}
+
+			if auth != tc.wantAuth || apiKey != tc.wantKey {
+				t.Fatalf("Authorization = %q, X-Api-Key = %q", auth, apiKey)
+			}
+
+			if body["keep_alive"] != tc.wantAlive {
+				t.Fatalf("keep_alive = %#v, want %#v", body["keep_alive"], tc.wantAlive)
+			}
+
+			if options, _ := body["options"].(map[string]any); options["num_ctx"] != float64(8192) {
+				t.Fatalf("options = %v", body["options"])
+			}
+		})
+	}
+}
+
+func TestOllama_RejectsInvalidSettings(t *testing.T) {
+	cases := map[string]providers.ProviderConfig{
+		"base url":   {BaseURL: "http://bad host"},
+		"keep alive": {Ollama: providers.OllamaConfig{KeepAlive: "soon"}},
+		"auth type":  {APIKey: "secret", AuthType: "basic"},
+	}
+
+	for name, cfg := range cases {
+		if _, err := providers.NewOllamaFromConfig(cfg); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestOllama_MessagesMapToRolesWithImagesPerTurn(t *testing.T) {
+	var body struct {
+		Messages []struct {
+			Role    string   `json:"role"`
+			Content string   `json:"content"`
+			Images  []string `json:"images"`
+		} `json:"messages"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		_, _ = w.Write([]byte(`{"model":"m","message":{"role":"assistant","content":"[]"},"done":true}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewOllamaFromConfig(providers.ProviderConfig{BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewOllamaFromConfig: %v", err)
+	}
+
+	if _, err := p.Chat(context.Background(), multiTurnOptions()); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	want := []struct {
+		role    string
+		content string
+		images  int
+	}{
+		{"system", "be precise", 0},
+		{"user", "example", 1},
+		{"assistant", `[{"label":"cat"}]`, 0},
+		{"user", "detect again", 1},
+	}
+
+	if len(body.Messages) != len(want) {
+		t.Fatalf("messages = %+v, want %d", body.Messages, len(want))
+	}
+
+	for i, w := range want {
+		if got := body.Messages[i]; got.Role != w.role || got.Content != w.content || len(got.Images) != w.images {
+			t.Fatalf("messages[%d] = %s %q with %d images, want %s %q with %d",
+				i, got.Role, got.Content, len(got.Images), w.role, w.content, w.images)
+		}
+	}
+}
@@ -0,0 +1,458 @@
+package providers_test
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestOpenAIResponses_StreamsOutputTextWithSchema(t *testing.T) {
+	var body map[string]any
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/responses" {
+			http.NotFound(w, r)
+			return
+		}
+
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		for _, ev := range []string{
+			`{"type":"response.output_text.delta","delta":"[{\"label\":"}`,
+			`{"type":"response.output_text.delta","delta":"\"cat\"}]"}`,
+			`{"type":"response.completed","response":{"usage":{"input_tokens":120,"output_tokens":30,` +
+				`"output_tokens_details":{"reasoning_tokens":10}}}}`,
+		} {
+			_, _ = io.WriteString(w, "data: "+ev+"\n\n")
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewOpenAI(providers.ProviderConfig{
+		APIKey:  "test",
+		BaseURL: srv.URL,
+		OpenAI:  providers.OpenAIConfig{API: "responses"},
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAI: %v", err)
+	}
+
+	var sb strings.Builder
+
+	opts := providers.NewChatOptions("gpt-test", "detect",
+		providers.WithStream(true),
+		providers.WithSystemPrompt("be precise"),
+		providers.WithImages([]byte("\x89PNG\r\n\x1a\n")),
+		providers.WithFormat(json.RawMessage(`{"type":"array"}`)),
+		providers.WithOnDelta(func(content, _ string) error {
+			sb.WriteString(content)
+			return nil
+		}),
+	)
+
+	usage, err := p.Chat(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if got := sb.String(); got != `[{"label":"cat"}]` {
+		t.Fatalf("content = %q", got)
+	}
+
+	if usage.PromptTokens != 120 || usage.CompletionTokens != 20 || usage.ThinkingTokens != 10 {
+		t.Fatalf("usage = %+v", usage)
+	}
+
+	if body["instructions"] != "be precise" {
+		t.Fatalf("instructions = %v", body["instructions"])
+	}
+
+	format, _ := body["text"].(map[string]any)["format"].(map[string]any)
+	if format["type"] != "json_schema" {
+		t.Fatalf("text.format = %v, want json_schema", format)
+	}
+
+	input := body["input"].([]any)[0].(map[string]any)["content"].([]any)
+	if len(input) != 2 || input[1].(map[string]any)["type"] != "input_image" {
+		t.Fatalf("input content = %v", input)
+	}
+}
+
+func TestAzureOpenAI_UsesDeploymentPathAndAPIKeyHeader(t *testing.T) {
+	var (
+		path, apiVersion, apiKey, auth string
+		model                          any
+	)
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		apiVersion = r.URL.Query().Get("api-version")
+		apiKey = r.Header.Get("Api-Key")
+		auth = r.Header.Get("Authorization")
+
+		var body map[string]any
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		model = body["model"]
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"choices":[{"message":{"role":"assistant","content":"[]"}}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.New("azure", providers.ProviderConfig{
+		APIKey:  "secret",
+		BaseURL: srv.URL,
+		Azure:   providers.AzureConfig{Deployment: "vision-prod", APIVersion: "2024-10-21"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := p.Chat(context.Background(), providers.NewChatOptions("gpt-4o", "detect")); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if path != "/openai/deployments/vision-prod/chat/completions" || apiVersion != "2024-10-21" {
+		t.Fatalf("path = %q api-version = %q", path, apiVersion)
+	}
+
+	if apiKey != "secret" || auth != "" {
+		t.Fatalf("api-key = %q authorization = %q", apiKey, auth)
+	}
+
+	if model != "vision-prod" {
+		t.Fatalf("model = %v, want deployment name", model)
+	}
+}
+
+func TestAzureOpenAI_ResponsesDefaultsToPreviewVersionAndCannotListModels(t *testing.T) {
+	var path, apiVersion string
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path, apiVersion = r.URL.Path, r.URL.Query().Get("api-version")
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"r","object":"response","status":"completed","output":[`+
+			`{"type":"message","role":"assistant","content":[{"type":"output_text","text":"[]"}]}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.New("azure", providers.ProviderConfig{
+		APIKey:  "secret",
+		BaseURL: srv.URL,
+		OpenAI:  providers.OpenAIConfig{API: "responses"},
+		Azure:   providers.AzureConfig{Deployment: "vision-prod"},
+	})
+	if err != nil {
+		t.Fatalf("New: %v", err)
+	}
+
+	if _, err := p.Chat(context.Background(), providers.NewChatOptions("gpt-4o", "detect")); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if path != "/openai/responses" || !strings.HasSuffix(apiVersion, "-preview") {
+		t.Fatalf("path = %q api-version = %q, want a preview version", path, apiVersion)
+	}
+
+	lister, ok := p.(providers.ModelLister)
+	if !ok {
+		t.Fatal("azure provider is not a ModelLister")
+	}
+
+	if _, err := lister.ListModels(context.Background()); !errors.Is(err, providers.ErrModelListUnsupported) {
+		t.Fatalf("ListModels err = %v, want ErrModelListUnsupported", err)
+	}
+}
+
+func TestOpenAI_HTTPConfigTrustsCAAndControlsHeaders(t *testing.T) {
+	var got http.Header
+
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"c","object":"chat.completion","model":"m","choices":[{"index":0,`+
+			`"message":{"role":"assistant","content":"[]"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := providers.NewOpenAI(providers.ProviderConfig{
+		APIKey:  "secret",
+		BaseURL: srv.URL,
+		HTTP: providers.HTTPConfig{
+			Timeout:        5 * time.Second,
+			CACert:         caFile,
+			Headers:        map[string]string{"X-Gateway-Tenant": "vision"},
+			UserAgent:      "dino-test",
+			NoAPIKeyHeader: true,
+		},
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAI: %v", err)
+	}
+
+	if _, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p")); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if got.Get("X-Api-Key") != "" || got.Get("Authorization") != "Bearer secret" {
+		t.Fatalf("auth headers: X-Api-Key=%q Authorization=%q", got.Get("X-Api-Key"), got.Get("Authorization"))
+	}
+
+	if got.Get("User-Agent") != "dino-test" || got.Get("X-Gateway-Tenant") != "vision" {
+		t.Fatalf("User-Agent=%q X-Gateway-Tenant=%q", got.Get("User-Agent"), got.Get("X-Gateway-Tenant"))
+	}
+}
+
+func TestOpenAI_OutputControlsAndImageDetail(t *testing.T) {
+	var body struct {
+		MaxCompletionTokens int      `json:"max_completion_tokens"`
+		Seed                *int64   `json:"seed"`
+		Stop                []string `json:"stop"`
+		Messages            []struct {
+			Content json.RawMessage `json:"content"`
+		} `json:"messages"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		raw, _ := io.ReadAll(r.Body)
+		_ = json.Unmarshal(raw, &body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"c","object":"chat.completion","model":"m","choices":[{"index":0,`+
+			`"message":{"role":"assistant","content":"[]"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewOpenAI(providers.ProviderConfig{APIKey: "test", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewOpenAI: %v", err)
+	}
+
+	opts := providers.NewChatOptions("m", "detect",
+		providers.WithImages([]byte("\x89PNG\r\n\x1a\n")),
+		providers.WithMaxTokens(512),
+		providers.WithSeed(0),
+		providers.WithStop("</answer>"),
+		providers.WithImageDetail("high"),
+	)
+	if _, err := p.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if body.MaxCompletionTokens != 512 || body.Seed == nil || *body.Seed != 0 ||
+		len(body.Stop) != 1 || body.Stop[0] != "</answer>" {
+		t.Fatalf("max_completion_tokens=%d seed=%v stop=%v", body.MaxCompletionTokens, body.Seed, body.Stop)
+	}
+
+	if !strings.Contains(string(body.Messages[0].Content), `"detail":"high"`) {
+		t.Fatalf("image part = %s, want detail high", body.Messages[0].Content)
+	}
+}
+
+func TestOpenAI_ReasoningEffortReplacesSamplingParameters(t *testing.T) {
+	cases := []struct {
+		name     string
+		opts     []providers.Option
+		effort   any // reasoning_effort sent, nil when absent
+		sampling bool
+	}{
+		{"plain", nil, nil, true},
+		{"think only", []providers.Option{providers.WithThink(true)}, "medium", false},
+		{"effort", []providers.Option{providers.WithReasoningEffort("low")}, "low", false},
+		{"effort none", []providers.Option{providers.WithReasoningEffort("none")}, "none", true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			var body map[string]any
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				_ = json.NewDecoder(r.Body).Decode(&body)
+
+				w.Header().Set("Content-Type", "application/json")
+				_, _ = io.WriteString(w, `{"id":"c","object":"chat.completion","model":"m","choices":[{"index":0,`+
+					`"message":{"role":"assistant","content":"[]"},"finish_reason":"stop"}]}`)
+			}))
+			t.Cleanup(srv.Close)
+
+			p, err := providers.NewOpenAI(providers.ProviderConfig{APIKey: "test", BaseURL: srv.URL})
+			if err != nil {
+				t.Fatalf("NewOpenAI: %v", err)
+			}
+
+			opts := append([]providers.Option{providers.WithTemperature(0.6), providers.WithTopP(0.95)}, tc.opts...)
+			if _, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p", opts...)); err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+
+			if body["reasoning_effort"] != tc.effort {
+				t.Fatalf("reasoning_effort = %v, want %v", body["reasoning_effort"], tc.effort)
+			}
+
+			_, hasTemp := body["temperature"]
+			_, hasTopP := body["top_p"]
+			if hasTemp != tc.sampling || hasTopP != tc.sampling {
+				t.Fatalf("temperature sent = %t, top_p sent = %t, want %t", hasTemp, hasTopP, tc.sampling)
+			}
+		})
+	}
+}
+
+// multiTurnOptions is a conversation with an example exchange before the image to detect on.
+func multiTurnOptions() providers.ChatOptions {
+	png := []byte("\x89PNG\r\n\x1a\n")
+
+	return providers.NewChatOptions("m", "detect again",
+		providers.WithSystemPrompt("be precise"),
+		providers.WithImages(png),
+		providers.WithMessages(
+			providers.Message{Role: providers.RoleUser, Content: "example", Images: [][]byte{png}},
+			providers.Message{Role: "model", Content: `[{"label":"cat"}]`},
+		),
+	)
+}
+
+// openAITurn is a message or input item in a Chat Completions or Responses request body.
+type openAITurn struct {
+	Role    string          `json:"role"`
+	Content json.RawMessage `json:"content"`
+}
+
+// parts returns the content part types of a user turn, or nil when the content is a string.
+func (m openAITurn) parts() []string {
+	var parts []struct {
+		Type string `json:"type"`
+	}
+	if json.Unmarshal(m.Content, &parts) != nil {
+		return nil
+	}
+
+	types := make([]string, 0, len(parts))
+	for _, p := range parts {
+		types = append(types, p.Type)
+	}
+	return types
+}
+
+func TestOpenAI_MessagesMapToChatCompletionsTurns(t *testing.T) {
+	var body struct {
+		Messages []openAITurn `json:"messages"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"c","object":"chat.completion","model":"m","choices":[{"index":0,`+
+			`"message":{"role":"assistant","content":"[]"},"finish_reason":"stop"}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewOpenAI(providers.ProviderConfig{APIKey: "test", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewOpenAI: %v", err)
+	}
+
+	if _, err := p.Chat(context.Background(), multiTurnOptions()); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	want := []struct {
+		role  string
+		parts string // content part types; empty for plain string content
+	}{
+		{"system", ""},
+		{"user", "text,image_url"},
+		{"assistant", ""},
+		{"user", "text,image_url"},
+	}
+
+	if len(body.Messages) != len(want) {
+		t.Fatalf("messages = %+v, want %d", body.Messages, len(want))
+	}
+
+	for i, w := range want {
+		got := body.Messages[i]
+		if parts := strings.Join(got.parts(), ","); got.Role != w.role || parts != w.parts {
+			t.Fatalf("messages[%d] = %s with parts %q, want %s with %q", i, got.Role, parts, w.role, w.parts)
+		}
+	}
+
+	if string(body.Messages[2].Content) != `"[{\"label\":\"cat\"}]"` {
+		t.Fatalf("assistant content = %s", body.Messages[2].Content)
+	}
+}
+
+func TestOpenAIResponses_MessagesMapToInputItems(t *testing.T) {
+	var body struct {
+		Instructions string       `json:"instructions"`
+		Input        []openAITurn `json:"input"`
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewDecoder(r.Body).Decode(&body)
+
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = io.WriteString(w, `{"id":"r","object":"response","status":"completed","output":[{"type":"message",`+
+			`"id":"m","role":"assistant","status":"completed","content":[{"type":"output_text","text":"[]"}]}]}`)
+	}))
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewOpenAI(providers.ProviderConfig{
+		APIKey:  "test",
+		BaseURL: srv.URL,
+		OpenAI:  providers.OpenAIConfig{API: "responses"},
+	})
+	if err != nil {
+		t.Fatalf("NewOpenAI: %v", err)
+	}
+
+	if _, err := p.Chat(context.Background(), multiTurnOptions()); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if body.Instructions != "be precise" {
+		t.Fatalf("instructions = %q", body.Instructions)
+	}
+
+	want := []struct {
+		role  string
+		parts string // content part types; empty for plain string content
+	}{
+		{"user", "input_text,input_image"},
+		{"assistant", ""},
+		{"user", "input_text,input_image"},
+	}
+
+	if len(body.Input) != len(want) {
+		t.Fatalf("input = %+v, want %d items", body.Input, len(want))
+	}
+
+	for i, w := range want {
+		got := body.Input[i]
+		if parts := strings.Join(got.parts(), ","); got.Role != w.role || parts != w.parts {
+			t.Fatalf("input[%d] = %s with parts %q, want %s with %q", i, got.Role, parts, w.role, w.parts)
+		}
+	}
+}
@@ -0,0 +1,165 @@
+package providers_test
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func TestEstimateImageTokens(t *testing.T) {
+	cases := []struct {
+		provider string
+		w, h     int
+		want     int
+	}{
+		// 1024x1024 -> 768x768 -> 2x2 tiles
+		{"openai", 1024, 1024, 85 + 170*4},
+		// 4096x2048 -> 2048x1024 -> 1536x768 -> 3x2 tiles
+		{"openai", 4096, 2048, 85 + 170*6},
+		{"gemini", 300, 300, 258},
+		{"gemini", 1920, 1080, 258 * 3 * 2},
+		{"anthropic", 1000, 1000, 1334},
+		{"ollama", 280, 560, 10 * 20},
+	}
+
+	for _, tc := range cases {
+		if got := providers.EstimateImageTokens(tc.provider, tc.w, tc.h); got != tc.want {
+			t.Errorf("%s %dx%d: got %d, want %d", tc.provider, tc.w, tc.h, got, tc.want)
+		}
+	}
+}
+
+// concurrencyProbe records how many Chat calls overlap, holding each for hold.
+type concurrencyProbe struct {
+	hold    time.Duration
+	current atomic.Int32
+	peak    atomic.Int32
+}
+
+func (p *concurrencyProbe) Chat(ctx context.Context, _ providers.ChatOptions) (providers.Usage, error) {
+	n := p.current.Add(1)
+	defer p.current.Add(-1)
+
+	for {
+		old := p.peak.Load()
+		if n <= old || p.peak.CompareAndSwap(old, n) {
+			break
+		}
+	}
+
+	select {
+	case <-time.After(p.hold):
+		return providers.Usage{}, nil
+	case <-ctx.Done():
+		return providers.Usage{}, ctx.Err()
+	}
+}
+
+func TestWithRateLimit_PacesRequests(t *testing.T) {
+	// 600 RPM refills one request every 100ms once the minute's budget is spent.
+	p := providers.WithRateLimit(&concurrencyProbe{}, "ollama", providers.RateLimit{RPM: 600})
+
+	for i := range 600 {
+		if _, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p")); err != nil {
+			t.Fatalf("call %d: %v", i, err)
+		}
+	}
+
+	start := time.Now()
+	if _, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p")); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Fatalf("request over the RPM budget waited %v, want about 100ms", waited)
+	}
+}
+
+func TestWithRateLimit_PacesTokens(t *testing.T) {
+	// 600 TPM refills one token every 100ms; the first prompt spends the whole budget.
+	p := providers.WithRateLimit(&concurrencyProbe{}, "ollama", providers.RateLimit{TPM: 600})
+
+	big := providers.NewChatOptions("m", strings.Repeat("x", 600*4))
+	if _, err := p.Chat(context.Background(), big); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	start := time.Now()
+	if _, err := p.Chat(context.Background(), providers.NewChatOptions("m", "four")); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if waited := time.Since(start); waited < 50*time.Millisecond {
+		t.Fatalf("request over the TPM budget waited %v, want about 100ms", waited)
+	}
+}
+
+func TestWithRateLimit_CapsInFlight(t *testing.T) {
+	probe := &concurrencyProbe{hold: 20 * time.Millisecond}
+	p := providers.WithRateLimit(probe, "ollama", providers.RateLimit{MaxInFlight: 2})
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Go(func() {
+			_, _ = p.Chat(context.Background(), providers.NewChatOptions("m", "p"))
+		})
+	}
+	wg.Wait()
+
+	if peak := probe.peak.Load(); peak != 2 {
+		t.Fatalf("peak concurrency = %d, want 2", peak)
+	}
+}
+
+func TestWithRateLimit_CancelWhileWaitingReturnsPromptly(t *testing.T) {
+	cases := map[string]struct {
+		limit providers.RateLimit
+		fill  func(ctx context.Context, p providers.Provider) // spends the budget so the next call has to wait
+	}{
+		"rpm": {
+			limit: providers.RateLimit{RPM: 1},
+			fill: func(ctx context.Context, p providers.Provider) {
+				_, _ = p.Chat(ctx, providers.NewChatOptions("m", "p"))
+			},
+		},
+		"in flight": {
+			limit: providers.RateLimit{MaxInFlight: 1},
+			fill: func(ctx context.Context, p providers.Provider) {
+				go func() { _, _ = p.Chat(ctx, providers.NewChatOptions("m", "p")) }()
+				time.Sleep(20 * time.Millisecond)
+			},
+		},
+	}
+
+	for name, tc := range cases {
+		t.Run(name, func(t *testing.T) {
+			probe := &concurrencyProbe{}
+			if tc.limit.MaxInFlight > 0 {
+				probe.hold = time.Minute
+			}
+
+			p := providers.WithRateLimit(probe, "ollama", tc.limit)
+			tc.fill(t.Context(), p)
+
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Millisecond)
+			defer cancel()
+
+			start := time.Now()
+			_, err := p.Chat(ctx, providers.NewChatOptions("m", "p"))
+
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("err = %v, want context.DeadlineExceeded", err)
+			}
+
+			if waited := time.Since(start); waited > time.Second {
+				t.Fatalf("cancelled wait took %v", waited)
+			}
+		})
+	}
+}
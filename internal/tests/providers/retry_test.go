@@ -0,0 +1,236 @@
+package providers_test
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/ai-is-coming/dino/internal/providers"
+)
+
+func newGeminiStub(t *testing.T, handler http.HandlerFunc) providers.Provider {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	p, err := providers.NewGemini(providers.ProviderConfig{APIKey: "test", BaseURL: srv.URL})
+	if err != nil {
+		t.Fatalf("NewGemini: %v", err)
+	}
+	return p
+}
+
+func TestWithRetry_RetriesTransientAndResetsPartialOutput(t *testing.T) {
+	var calls atomic.Int32
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.Header().Set("Retry-After", "0")
+			http.Error(w, `{"error":{"code":503,"status":"UNAVAILABLE","message":"overloaded"}}`, http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"candidates":[{"content":{"parts":[{"text":"[]"}]}}]}`))
+	})
+
+	var (
+		sb      strings.Builder
+		retries int
+	)
+
+	sb.WriteString("partial")
+
+	rp := providers.WithRetry(p, providers.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	opts := providers.NewChatOptions("m", "p",
+		providers.WithOnDelta(func(content, _ string) error {
+			sb.WriteString(content)
+			return nil
+		}),
+		providers.WithOnRetry(func(int, error, time.Duration) {
+			retries++
+			sb.Reset()
+		}),
+	)
+
+	if _, err := rp.Chat(context.Background(), opts); err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	if calls.Load() != 2 || retries != 1 {
+		t.Fatalf("calls=%d retries=%d, want 2 and 1", calls.Load(), retries)
+	}
+
+	if sb.String() != "[]" {
+		t.Fatalf("content = %q, want %q", sb.String(), "[]")
+	}
+}
+
+func TestWithRetry_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int32
+
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, `{"error":{"code":400,"status":"INVALID_ARGUMENT","message":"bad"}}`, http.StatusBadRequest)
+	})
+
+	rp := providers.WithRetry(p, providers.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond})
+	if _, err := rp.Chat(context.Background(), providers.NewChatOptions("m", "p")); err == nil {
+		t.Fatal("expected error")
+	}
+
+	if calls.Load() != 1 {
+		t.Fatalf("calls = %d, want 1", calls.Load())
+	}
+}
+
+func TestRetryAfter_ParsesHeaderSeconds(t *testing.T) {
+	p := newGeminiStub(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "7")
+		http.Error(w, "slow down", http.StatusTooManyRequests)
+	})
+
+	_, err := p.Chat(context.Background(), providers.NewChatOptions("m", "p"))
+	if !providers.IsRetryable(err) {
+		t.Fatalf("expected retryable error, got %v", err)
+	}
+
+	if got := providers.RetryAfter(err); got != 7*time.Second {
+		t.Fatalf("RetryAfter = %s, want 7s", got)
+	}
+}
+
+func TestWithRetry_RetriesCutStreams(t *testing.T) {
+	cases := []struct {
+		name     string
+		cut      []string // SSE payloads of a stream that stops before finishing
+		complete []string
+		newP     func(t *testing.T, url string) providers.Provider
+	}{
+		{
+			name: "openai",
+			cut: []string{
+				`{"id":"c","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"[{\"la"}}]}`,
+			},
+			complete: []string{
+				`{"id":"c","object":"chat.completion.chunk","choices":[{"index":0,"delta":{"content":"[]"}}]}`,
+				`{"id":"c","object":"chat.completion.chunk","choices":[{"index":0,"delta":{},"finish_reason":"stop"}]}`,
+				`[DONE]`,
+			},
+			newP: func(t *testing.T, url string) providers.Provider {
+				p, err := providers.NewOpenAI(providers.ProviderConfig{APIKey: "test", BaseURL: url})
+				if err != nil {
+					t.Fatalf("NewOpenAI: %v", err)
+				}
+				return p
+			},
+		},
+		{
+			name:     "gemini",
+			cut:      []string{`{"candidates":[{"content":{"parts":[{"text":"[{\"la"}]}}]}`},
+			complete: []string{`{"candidates":[{"content":{"parts":[{"text":"[]"}]},"finishReason":"STOP"}]}`},
+			newP: func(t *testing.T, url string) providers.Provider {
+				p, err := providers.NewGemini(providers.ProviderConfig{APIKey: "test", BaseURL: url})
+				if err != nil {
+					t.Fatalf("NewGemini: %v", err)
+				}
+				return p
+			},
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			var calls atomic.Int32
+
+			srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				events := c.complete
+				if calls.Add(1) == 1 {
+					events = c.cut
+				}
+
+				w.Header().Set("Content-Type", "text/event-stream")
+				for _, ev := range events {
+					_, _ = w.Write([]byte("data: " + ev + "\n\n"))
+				}
+			}))
+			t.Cleanup(srv.Close)
+
+			var sb strings.Builder
+
+			policy := providers.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+			rp := providers.WithRetry(c.newP(t, srv.URL), policy)
+			opts := providers.NewChatOptions("m", "p",
+				providers.WithStream(true),
+				providers.WithOnDelta(func(content, _ string) error {
+					sb.WriteString(content)
+					return nil
+				}),
+				providers.WithOnRetry(func(int, error, time.Duration) { sb.Reset() }),
+			)
+
+			if _, err := rp.Chat(context.Background(), opts); err != nil {
+				t.Fatalf("Chat: %v", err)
+			}
+
+			if calls.Load() != 2 || sb.String() != "[]" {
+				t.Fatalf("calls=%d content=%q, want 2 and %q", calls.Load(), sb.String(), "[]")
+			}
+		})
+	}
+}
+
+// usageSequence answers call i with usages[i] and errs[i].
+type usageSequence struct {
+	usages []providers.Usage
+	errs   []error
+	calls  int
+}
+
+func (s *usageSequence) Chat(context.Context, providers.ChatOptions) (providers.Usage, error) {
+	i := s.calls
+	s.calls++
+
+	return s.usages[i], s.errs[i]
+}
+
+func TestWithRetry_AddsUpUsageAcrossAttempts(t *testing.T) {
+	cut := fmt.Errorf("stream: %w", io.ErrUnexpectedEOF)
+	seq := &usageSequence{
+		usages: []providers.Usage{
+			{PromptTokens: 100, CompletionTokens: 5}, // billed before the stream was cut
+			{ImageTokens: 85},                        // rejected outright; only the local estimate
+			{PromptTokens: 100, ImageTokens: 85, CompletionTokens: 20},
+		},
+		errs: []error{cut, cut, nil},
+	}
+
+	policy := providers.RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}
+
+	usage, err := providers.WithRetry(seq, policy).Chat(context.Background(), providers.NewChatOptions("m", "p"))
+	if err != nil {
+		t.Fatalf("Chat: %v", err)
+	}
+
+	want := providers.Usage{PromptTokens: 200, ImageTokens: 85, CompletionTokens: 25}
+	if usage != want {
+		t.Fatalf("usage = %+v, want %+v", usage, want)
+	}
+
+	// Usage already billed is kept when the context ends during the backoff.
+	ctx, cancel := context.WithCancel(context.Background())
+	seq = &usageSequence{usages: []providers.Usage{{PromptTokens: 100, CompletionTokens: 5}}, errs: []error{cut}}
+	opts := providers.NewChatOptions("m", "p", providers.WithOnRetry(func(int, error, time.Duration) { cancel() }))
+
+	policy.InitialBackoff = time.Minute
+	usage, err = providers.WithRetry(seq, policy).Chat(ctx, opts)
+
+	if err == nil || usage.PromptTokens != 100 || usage.CompletionTokens != 5 {
+		t.Fatalf("Chat = %+v, %v; want the first attempt's usage and an error", usage, err)
+	}
+}
@@ -0,0 +1,103 @@
+package utils_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/utils"
+)
+
+// jpegWithOrientation encodes a small JPEG and inserts an EXIF APP1 segment after SOI.
+func jpegWithOrientation(t *testing.T, order binary.ByteOrder, orientation uint16) []byte {
+	t.Helper()
+
+	var img bytes.Buffer
+	if err := jpeg.Encode(&img, image.NewGray(image.Rect(0, 0, 4, 4)), nil); err != nil {
+		t.Fatal(err)
+	}
+
+	tiff := make([]byte, 26)
+	if order == binary.BigEndian {
+		copy(tiff, "MM\x00*")
+	} else {
+		copy(tiff, "II*\x00")
+	}
+	order.PutUint32(tiff[4:], 8)            // IFD0 offset
+	order.PutUint16(tiff[8:], 1)            // one entry
+	order.PutUint16(tiff[10:], 0x0112)      // Orientation
+	order.PutUint16(tiff[12:], 3)           // SHORT
+	order.PutUint32(tiff[14:], 1)           // count
+	order.PutUint16(tiff[18:], orientation) // value
+
+	payload := append([]byte("Exif\x00\x00"), tiff...)
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+
+	out := append([]byte{}, img.Bytes()[:2]...)
+	out = append(out, seg...)
+	out = append(out, payload...)
+	return append(out, img.Bytes()[2:]...)
+}
+
+func TestExifOrientation(t *testing.T) {
+	if got := utils.ExifOrientation(jpegWithOrientation(t, binary.LittleEndian, 6)); got != 6 {
+		t.Fatalf("little endian: got %d, want 6", got)
+	}
+	if got := utils.ExifOrientation(jpegWithOrientation(t, binary.BigEndian, 8)); got != 8 {
+		t.Fatalf("big endian: got %d, want 8", got)
+	}
+	if got := utils.ExifOrientation(jpegWithOrientation(t, binary.BigEndian, 42)); got != 1 {
+		t.Fatalf("invalid value: got %d, want 1", got)
+	}
+
+	// The decoded image must still be a valid JPEG with the segment inserted.
+	if _, err := jpeg.Decode(bytes.NewReader(jpegWithOrientation(t, binary.LittleEndian, 3))); err != nil {
+		t.Fatal(err)
+	}
+
+	var plain bytes.Buffer
+	if err := jpeg.Encode(&plain, image.NewGray(image.Rect(0, 0, 2, 2)), nil); err != nil {
+		t.Fatal(err)
+	}
+	if got := utils.ExifOrientation(plain.Bytes()); got != 1 {
+		t.Fatalf("no exif: got %d, want 1", got)
+	}
+	if got := utils.ExifOrientation([]byte("not an image")); got != 1 {
+		t.Fatalf("garbage: got %d, want 1", got)
+	}
+}
+
+func TestApplyOrientation(t *testing.T) {
+	// A 3x2 image, stored sideways or mirrored, with its upright top-left corner marked.
+	red := color.RGBA{255, 0, 0, 255}
+
+	// Where the upright top-left pixel is stored for each orientation.
+	stored := map[int]image.Point{
+		1: {0, 0}, 2: {2, 0}, 3: {2, 1}, 4: {0, 1},
+		5: {0, 0}, 6: {0, 1}, 7: {2, 1}, 8: {2, 0},
+	}
+
+	for o, p := range stored {
+		img := image.NewRGBA(image.Rect(0, 0, 3, 2))
+		img.Set(p.X, p.Y, red)
+
+		out := utils.ApplyOrientation(img, o)
+
+		wantW, wantH := 3, 2
+		if o >= 5 {
+			wantW, wantH = 2, 3
+		}
+		if b := out.Bounds(); b.Dx() != wantW || b.Dy() != wantH {
+			t.Errorf("orientation %d: size %dx%d, want %dx%d", o, b.Dx(), b.Dy(), wantW, wantH)
+			continue
+		}
+
+		if got := color.RGBAModel.Convert(out.At(0, 0)); got != red {
+			t.Errorf("orientation %d: top-left is %v, want red", o, got)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package utils_test
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+
+	"github.com/ai-is-coming/dino/internal/utils"
+)
+
+func TestMatchGlob(t *testing.T) {
+	cases := []struct {
+		pattern, rel string
+		want         bool
+	}{
+		{"*.jpg", "a.jpg", true},
+		{"*.jpg", "site/cam/a.jpg", true},
+		{"*.jpg", "a.png", false},
+		{"thumbs", "site/thumbs", true},
+		{"site/*/a.jpg", "site/cam/a.jpg", true},
+		{"site/*/a.jpg", "site/cam/day/a.jpg", false},
+		{"site/**/a.jpg", "site/a.jpg", true},
+		{"site/**/a.jpg", "site/cam/day/a.jpg", true},
+		{"**/day", "site/cam/day", true},
+		{"site/**", "other/a.jpg", false},
+		{"", "a.jpg", false},
+	}
+	for _, c := range cases {
+		if got := utils.MatchGlob(c.pattern, c.rel); got != c.want {
+			t.Errorf("MatchGlob(%q, %q) = %t, want %t", c.pattern, c.rel, got, c.want)
+		}
+	}
+}
+
+func TestFindImages_RecursiveWithGlobs(t *testing.T) {
+	root := t.TempDir()
+	for _, f := range []string{
+		"a.jpg", "a.png", "notes.txt",
+		"site/cam1/day1/x.jpg", "site/cam1/day1/y.png",
+		"site/cam2/thumbs/t.jpg", "site/cam2/z.jpg",
+	} {
+		writeFile(t, filepath.Join(root, f))
+	}
+
+	rel := func(paths []string) []string {
+		out := make([]string, 0, len(paths))
+		for _, p := range paths {
+			r, _ := filepath.Rel(root, p)
+			out = append(out, filepath.ToSlash(r))
+		}
+		return out
+	}
+
+	flat, err := utils.FindImages(root, utils.WalkOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.jpg", "a.png"}; !slices.Equal(rel(flat), want) {
+		t.Fatalf("flat = %v, want %v", rel(flat), want)
+	}
+
+	got, err := utils.FindImages(root, utils.WalkOptions{
+		Recursive: true,
+		Include:   []string{"*.jpg"},
+		Exclude:   []string{"thumbs"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"a.jpg", "site/cam1/day1/x.jpg", "site/cam2/z.jpg"}; !slices.Equal(rel(got), want) {
+		t.Fatalf("recursive = %v, want %v", rel(got), want)
+	}
+
+	if _, err := utils.FindImages(root, utils.WalkOptions{Include: []string{"[a-"}}); err == nil {
+		t.Fatal("expected an error for an invalid glob")
+	}
+}
+
+func TestFindImages_SymlinkPolicies(t *testing.T) {
+	root := t.TempDir()
+	writeFile(t, filepath.Join(root, "real", "a.jpg"))
+
+	outside := t.TempDir()
+	writeFile(t, filepath.Join(outside, "b.jpg"))
+
+	symlink(t, filepath.Join(outside, "b.jpg"), filepath.Join(root, "b.jpg"))
+	symlink(t, outside, filepath.Join(root, "linked"))
+	symlink(t, root, filepath.Join(root, "real", "loop")) // must not recurse forever
+
+	count := func(policy string) int {
+		t.Helper()
+
+		imgs, err := utils.FindImages(root, utils.WalkOptions{Recursive: true, Symlinks: policy})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(imgs)
+	}
+
+	// real/a.jpg, b.jpg, and linked/b.jpg when folders are followed
+	if n := count(utils.SymlinksSkip); n != 1 {
+		t.Errorf("skip: %d images, want 1", n)
+	}
+	if n := count(""); n != 2 {
+		t.Errorf("files: %d images, want 2", n)
+	}
+	if n := count(utils.SymlinksFollow); n != 3 {
+		t.Errorf("follow: %d images, want 3", n)
+	}
+
+	if _, err := utils.FindImages(root, utils.WalkOptions{Symlinks: "always"}); err == nil {
+		t.Fatal("expected an error for an unknown symlink policy")
+	}
+}
+
+func writeFile(t *testing.T, path string) {
+	t.Helper()
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func symlink(t *testing.T, target, link string) {
+	t.Helper()
+
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+}
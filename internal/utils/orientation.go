@@ -0,0 +1,141 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	draw "image/draw"
+)
+
+const (
+	exifOrientationTag = 0x0112
+	tiffTypeShort      = 3
+	tiffHeaderLen      = 8
+	tiffEntryLen       = 12
+)
+
+// ExifOrientation returns the EXIF Orientation (1-8) of a JPEG or TIFF file, or 1 when the
+// tag is missing or unreadable.
+func ExifOrientation(data []byte) int {
+	if tiffData, ok := exifTIFF(data); ok {
+		return tiffOrientation(tiffData)
+	}
+	return 1
+}
+
+// exifTIFF returns the TIFF structure holding the EXIF tags: the APP1 payload of a JPEG, or
+// the file itself for a TIFF.
+func exifTIFF(data []byte) ([]byte, bool) {
+	if bytes.HasPrefix(data, []byte("II*\x00")) || bytes.HasPrefix(data, []byte("MM\x00*")) {
+		return data, true
+	}
+
+	if !bytes.HasPrefix(data, []byte{0xFF, 0xD8}) {
+		return nil, false
+	}
+
+	// Walk the JPEG markers up to the image data.
+	for i := 2; i+4 <= len(data) && data[i] == 0xFF; {
+		marker := data[i+1]
+		if marker == 0xDA || marker == 0xD9 { // start of scan, end of image
+			break
+		}
+
+		size := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + size
+		if size < 2 || end > len(data) {
+			break
+		}
+
+		if payload := data[i+4 : end]; marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			return payload[6:], true
+		}
+		i = end
+	}
+	return nil, false
+}
+
+// tiffOrientation reads the Orientation tag from the first IFD.
+func tiffOrientation(t []byte) int {
+	if len(t) < tiffHeaderLen {
+		return 1
+	}
+
+	var order binary.ByteOrder = binary.LittleEndian
+	if t[0] == 'M' {
+		order = binary.BigEndian
+	}
+
+	ifd := int(order.Uint32(t[4:]))
+	if ifd < tiffHeaderLen || ifd+2 > len(t) {
+		return 1
+	}
+
+	n := int(order.Uint16(t[ifd:]))
+	for i := range n {
+		e := ifd + 2 + i*tiffEntryLen
+		if e+tiffEntryLen > len(t) {
+			break
+		}
+
+		if order.Uint16(t[e:]) != exifOrientationTag || order.Uint16(t[e+2:]) != tiffTypeShort {
+			continue
+		}
+
+		if o := int(order.Uint16(t[e+8:])); o >= 1 && o <= 8 {
+			return o
+		}
+		break
+	}
+	return 1
+}
+
+// ApplyOrientation returns img transformed so it displays upright for the given EXIF
+// orientation; 1 and unknown values return img unchanged. Orientations 5-8 swap width and
+// height. The result starts at (0, 0).
+func ApplyOrientation(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	src := image.NewRGBA(image.Rect(0, 0, b.Dx(), b.Dy()))
+	draw.Draw(src, src.Bounds(), img, b.Min, draw.Src)
+
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := range dh {
+		for x := range dw {
+			sx, sy := orientedSource(orientation, x, y, w, h)
+			copy(dst.Pix[dst.PixOffset(x, y):][:4], src.Pix[src.PixOffset(sx, sy):][:4])
+		}
+	}
+	return dst
+}
+
+// orientedSource maps a pixel of the upright image back to the stored w x h image.
+func orientedSource(orientation, x, y, w, h int) (int, int) {
+	switch orientation {
+	case 2: // mirrored
+		return w - 1 - x, y
+	case 3: // rotated 180
+		return w - 1 - x, h - 1 - y
+	case 4: // mirrored vertically
+		return x, h - 1 - y
+	case 5: // transposed
+		return y, x
+	case 6: // stored rotated 90 counter-clockwise, so turn it clockwise
+		return y, h - 1 - x
+	case 7: // transversed
+		return w - 1 - y, h - 1 - x
+	case 8: // stored rotated 90 clockwise, so turn it counter-clockwise
+		return w - 1 - y, x
+	default:
+		return x, y
+	}
+}
@@ -1,9 +1,11 @@
 package utils
 
 import (
+	"fmt"
 	"image"
 	"image/color"
 	draw "image/draw"
+	"os"
 	"path/filepath"
 	"strings"
 
@@ -58,11 +60,53 @@ func DrawRect(img *image.RGBA, x1, y1, x2, y2 int, c color.Color, thickness int)
 	}
 }
 
+// WriteFileAtomic writes data to a temporary file next to path and renames it into place, so
+// readers never observe a partially written file.
+func WriteFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("chmod %s: %w", path, err)
+	}
+
+	// Flush before the rename, or a crash can leave an empty file under the final name.
+	if err := tmp.Sync(); err != nil {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+
+		return fmt.Errorf("sync %s: %w", path, err)
+	}
+
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		_ = os.Remove(tmp.Name())
+		return fmt.Errorf("rename %s: %w", path, err)
+	}
+	return nil
+}
+
 // IsImageFile returns true if the file has a common image extension.
 func IsImageFile(p string) bool {
 	ext := strings.ToLower(filepath.Ext(p))
 	switch ext {
-	case ".jpg", ".jpeg", ".png", ".bmp", ".gif", ".webp":
+	case ".jpg", ".jpeg", ".png", ".bmp", ".gif", ".webp", ".tif", ".tiff":
 		return true
 	default:
 		return false
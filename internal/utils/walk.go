@@ -0,0 +1,186 @@
+package utils
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Symlink policies for WalkOptions.Symlinks.
+const (
+	SymlinksFiles  = "files"  // follow links to files but not to folders (default)
+	SymlinksFollow = "follow" // follow links to files and folders; loops are visited once
+	SymlinksSkip   = "skip"   // ignore links entirely
+)
+
+// WalkOptions controls which files FindImages returns.
+type WalkOptions struct {
+	Recursive bool     // descend into subfolders
+	Include   []string // globs a file must match, relative to the root; empty takes every image
+	Exclude   []string // globs for files or folders to leave out
+	Symlinks  string   // one of the Symlinks* policies; empty means SymlinksFiles
+}
+
+// FindImages returns the image files under root, sorted, as paths joined onto root.
+//
+// Globs use forward slashes and path.Match syntax per segment, with "**" spanning any number
+// of folders. A glob without a slash is matched against the base name at any depth, so
+// "*.jpg" and "thumbs" work without a "**/" prefix.
+func FindImages(root string, opts WalkOptions) ([]string, error) {
+	if err := validateGlobs(opts.Include, opts.Exclude); err != nil {
+		return nil, err
+	}
+
+	switch opts.Symlinks {
+	case "":
+		opts.Symlinks = SymlinksFiles
+	case SymlinksFiles, SymlinksFollow, SymlinksSkip:
+	default:
+		return nil, fmt.Errorf("invalid symlink policy %q (want files, follow or skip)", opts.Symlinks)
+	}
+
+	w := &walker{opts: opts, seen: map[string]bool{}}
+	if real, err := filepath.EvalSymlinks(root); err == nil {
+		w.seen[real] = true
+	}
+
+	if err := w.walk(root, ""); err != nil {
+		return nil, err
+	}
+
+	sort.Strings(w.found)
+	return w.found, nil
+}
+
+// MatchGlob reports whether the slash-separated relative path rel matches pattern; see
+// FindImages for the syntax.
+func MatchGlob(pattern, rel string) bool {
+	pattern = strings.Trim(filepath.ToSlash(strings.TrimSpace(pattern)), "/")
+	if pattern == "" {
+		return false
+	}
+
+	if !strings.Contains(pattern, "/") {
+		ok, _ := path.Match(pattern, path.Base(rel))
+		return ok
+	}
+	return matchSegments(strings.Split(pattern, "/"), strings.Split(rel, "/"))
+}
+
+func matchSegments(pat, segs []string) bool {
+	for len(pat) > 0 {
+		if pat[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchSegments(pat[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+
+		if len(segs) == 0 {
+			return false
+		}
+
+		if ok, _ := path.Match(pat[0], segs[0]); !ok {
+			return false
+		}
+		pat, segs = pat[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
+
+func matchAny(patterns []string, rel string) bool {
+	for _, p := range patterns {
+		if MatchGlob(p, rel) {
+			return true
+		}
+	}
+	return false
+}
+
+func validateGlobs(lists ...[]string) error {
+	for _, patterns := range lists {
+		for _, p := range patterns {
+			for _, seg := range strings.Split(filepath.ToSlash(p), "/") {
+				if _, err := path.Match(seg, ""); err != nil {
+					return fmt.Errorf("invalid glob %q: %w", p, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// walker collects image files; seen holds the real paths of folders already entered.
+type walker struct {
+	opts  WalkOptions
+	seen  map[string]bool
+	found []string
+}
+
+func (w *walker) walk(dir, rel string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("read input dir: %w", err)
+	}
+
+	for _, de := range entries {
+		p := filepath.Join(dir, de.Name())
+		r := path.Join(rel, de.Name())
+
+		isDir := de.IsDir()
+		if de.Type()&fs.ModeSymlink != 0 {
+			if w.opts.Symlinks == SymlinksSkip {
+				continue
+			}
+
+			fi, err := os.Stat(p)
+			if err != nil { // dangling link
+				continue
+			}
+
+			isDir = fi.IsDir()
+			if isDir && w.opts.Symlinks != SymlinksFollow {
+				continue
+			}
+		}
+
+		if matchAny(w.opts.Exclude, r) {
+			continue
+		}
+
+		if isDir {
+			if w.opts.Recursive && w.enter(p) {
+				if err := w.walk(p, r); err != nil {
+					return err
+				}
+			}
+
+			continue
+		}
+
+		if IsImageFile(p) && (len(w.opts.Include) == 0 || matchAny(w.opts.Include, r)) {
+			w.found = append(w.found, p)
+		}
+	}
+	return nil
+}
+
+// enter reports whether dir has not been walked yet, guarding against symlink loops.
+func (w *walker) enter(dir string) bool {
+	real, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return false
+	}
+
+	if w.seen[real] {
+		return false
+	}
+	w.seen[real] = true
+	return true
+}